@@ -52,6 +52,8 @@ var accountingReportCmd = &cobra.Command{
 	Use:   "report",
 	Short: "Reports all project information",
 	Run: func(cmd *cobra.Command, args []string) {
+		// the report is written to files, stdout only lists them
+		tableOutputOnly(cmd)
 		head, _ := cmd.Flags().GetInt("limit")
 		conc, _ := cmd.Flags().GetInt("concurrency")
 		userAlso, _ := cmd.Flags().GetBool("user-also")
@@ -128,17 +130,32 @@ var accountingReportCmd = &cobra.Command{
 		if pushEOS {
 			saveToEOS(files...)
 		}
+
+		if getAccountingHistoryDB() != "" {
+			saveAccountingHistory(infos, timeNow(asYesterday))
+			fmt.Printf("%s\n", getAccountingHistoryDB())
+		}
 	},
 }
 
+// account used to store the accounting files in EOS
+const accountingEOSUser = "ml001"
+
+// accountingEOSDir is the directory in EOS where the accounting files of each day
+// are stored, under <dir>/<YYYY>/<MM>/<DD>
+var accountingEOSDir = func() string {
+	return path.Join(getTopology().Project.path("fdo"), "www/accounting/data/cernbox")
+}
+
 // storage files into cernbox project in EOS
 var saveToEOS = func(files ...string) {
 	ctx := getCtx()
-	client := getEOS("root://eosproject-f.cern.ch")
+	client := getEOSForProject("fdo")
 	key := time.Now().Local().Format("2006/01/02")
-	ctx, _ = context.WithTimeout(ctx, time.Second*30)
-	dir := "/eos/project/f/fdo/www/accounting/data/cernbox/"
-	err := client.CreateDir(ctx, "ml001", path.Join(dir, key))
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	dir := accountingEOSDir()
+	err := client.CreateDir(ctx, accountingEOSUser, path.Join(dir, key))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error creating accounting directory in EOS: %+v\n", err)
 		er(err)
@@ -154,7 +171,7 @@ var saveToEOS = func(files ...string) {
 		}
 		defer fd.Close()
 		name := path.Join(dir, key, path.Base(f))
-		err = client.Write(ctx, "ml001", name, fd)
+		err = client.Write(ctx, accountingEOSUser, name, fd)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error writing file: %+v\n", err)
 			er(err)
@@ -205,30 +222,8 @@ var getCost = func(bytes int, factorCost float64) string {
 }
 
 var computeAggregateReceiverJSON = func(infos []*projectInfo, file string, asYesterday bool) {
-	infos = uniqueInfos(infos)
-	aggregate := map[string]map[string]eosclient.QuotaInfo{}
-	for _, info := range infos {
-		group := info.chargeInfo.ChargeGroup
-		role := info.chargeInfo.ChargeRole
-		roles, ok := aggregate[group]
-		if !ok {
-			aggregate[group] = map[string]eosclient.QuotaInfo{
-				role: *info.QuotaInfo,
-			}
-		}
-
-		// here we have the group, we don't know if we have the role
-		if _, ok := roles[role]; !ok {
-			aggregate[group][role] = *info.QuotaInfo
-
-		}
-
-		// at this point we are sure we have the group and the role
-		quota, _ := aggregate[group][role]
-		quota.AvailableBytes += info.QuotaInfo.AvailableBytes
-		quota.UsedBytes += info.QuotaInfo.UsedBytes
-
-	}
+	// remove duplicate quota entries
+	aggregate := aggregateByGroupAndRole(uniqueInfos(infos))
 
 	payload_data := []*accReceiverJSON_v3_data{}
 	for group, roles := range aggregate {
@@ -484,30 +479,28 @@ var computeAggregateSimplified = func(infos []*projectInfo, file string, costFac
 	save(cols, rows, file)
 
 }
-var computeAggregate = func(infos []*projectInfo, file string, costFactor float64) {
+
+// aggregateByGroupAndRole sums the quotas of the infos by charge group and charge role.
+var aggregateByGroupAndRole = func(infos []*projectInfo) map[string]map[string]eosclient.QuotaInfo {
 	aggregate := map[string]map[string]eosclient.QuotaInfo{}
 	for _, info := range infos {
 		group := info.chargeInfo.ChargeGroup
 		role := info.chargeInfo.ChargeRole
-		roles, ok := aggregate[group]
-		if !ok {
-			aggregate[group] = map[string]eosclient.QuotaInfo{
-				role: *info.QuotaInfo,
-			}
-		}
-
-		// here we have the group, we don't know if we have the role
-		if _, ok := roles[role]; !ok {
-			aggregate[group][role] = *info.QuotaInfo
-
+		if _, ok := aggregate[group]; !ok {
+			aggregate[group] = map[string]eosclient.QuotaInfo{}
 		}
 
-		// at this point we are sure we have the group and the role
-		quota, _ := aggregate[group][role]
+		quota := aggregate[group][role]
 		quota.AvailableBytes += info.QuotaInfo.AvailableBytes
 		quota.UsedBytes += info.QuotaInfo.UsedBytes
-
+		aggregate[group][role] = quota
 	}
+	return aggregate
+}
+
+var computeAggregate = func(infos []*projectInfo, file string, costFactor float64) {
+	// remove duplicate quota entries
+	aggregate := aggregateByGroupAndRole(uniqueInfos(infos))
 
 	// sort by charge groups
 	keys := make([]string, 0, len(aggregate))
@@ -560,7 +553,7 @@ var computeAggregate = func(infos []*projectInfo, file string, costFactor float6
 // cleans roles and groups
 var fillChargeRoles = func(infos []*projectInfo) {
 	for _, info := range infos {
-		if strings.HasPrefix(info.FileInfo.File, "/eos/project/") {
+		if strings.HasPrefix(info.FileInfo.File, getTopology().Project.Prefix+"/") {
 			info.chargeInfo.ChargeRole = "CERNBox Project " + path.Base(info.FileInfo.File)
 		} else {
 			info.chargeInfo.ChargeRole = info.userInfo.accountTypeHuman()
@@ -747,17 +740,16 @@ var getUsername = func(uid uint64) (string, error) {
 var getEOSUsers = func(limit int) (infos []*projectInfo) {
 	ctx := getCtx()
 	var mds []*eosclient.FileInfo
-	letters := "abcdefghijklmnopqrstuvwxyz"
+	ns := getTopology().Home
 	spin := NewDescriptionSpinStatus("Getting users")
 	spin.Start()
 
-	for i := 0; i < len(letters); i++ {
-		letter := string(letters[i])
+	for _, letter := range ns.letters() {
 		spin.UpdateDescription(fmt.Sprintf("[%s]", letter))
-		host := fmt.Sprintf("root://eoshome-%s.cern.ch", letter)
-		client := getEOS(host)
-		ctx, _ := context.WithTimeout(ctx, time.Second*30)
-		m, err := client.List(ctx, "root", "/eos/user/"+letter)
+		client := getEOS(ns.mgm(letter))
+		ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+		m, err := client.List(ctx, "root", ns.dir(letter))
+		cancel()
 		if err != nil {
 			er(err)
 		}
@@ -785,19 +777,32 @@ var getEOSUsers = func(limit int) (infos []*projectInfo) {
 
 	return
 }
+
+// chargedProjects drops the archives and other dot-folders, which are not
+// projects, and the retired projects, which are not charged anymore.
+func chargedProjects(mds []*eosclient.FileInfo) []*eosclient.FileInfo {
+	retired := getRetiredPaths()
+	projects := []*eosclient.FileInfo{}
+	for _, m := range mds {
+		if !strings.HasPrefix(path.Base(m.File), ".") && !retired[path.Clean(m.File)] {
+			projects = append(projects, m)
+		}
+	}
+	return projects
+}
+
 var getEOSProjects = func(limit int) (infos []*projectInfo) {
 	ctx := getCtx()
 	var mds []*eosclient.FileInfo
-	letters := "abcdefghijklmnopqrstuvwxyz"
+	ns := getTopology().Project
 	spin := NewDescriptionSpinStatus("Getting project names")
 	spin.Start()
-	for i := 0; i < len(letters); i++ {
-		letter := string(letters[i])
+	for _, letter := range ns.letters() {
 		spin.UpdateDescription(fmt.Sprintf("[%s]", letter))
-		host := fmt.Sprintf("root://eosproject-%s.cern.ch", letter)
-		client := getEOS(host)
-		ctx, _ := context.WithTimeout(ctx, time.Second*30)
-		m, err := client.List(ctx, "root", "/eos/project/"+letter)
+		client := getEOS(ns.mgm(letter))
+		ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+		m, err := client.List(ctx, "root", ns.dir(letter))
+		cancel()
 		if err != nil {
 			er(err)
 		}
@@ -805,6 +810,8 @@ var getEOSProjects = func(limit int) (infos []*projectInfo) {
 	}
 	spin.Done()
 
+	mds = chargedProjects(mds)
+
 	if limit == -1 {
 		limit = len(mds)
 	}
@@ -854,13 +861,14 @@ func getQuotas(mgms ...string) map[string]*eosclient.QuotaInfo {
 	spin.Start()
 	for _, mgm := range mgms {
 		spin.UpdateDescription(mgm)
-		ctx, _ := context.WithTimeout(getCtx(), time.Second*60)
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
 		eos := getEOS(mgm)
-		prefix := "/eos/project/"
-		if strings.Contains(mgm, "home") {
-			prefix = "/eos/user/"
+		prefix := getTopology().Project.Prefix + "/"
+		if getTopology().kindOfMGM(mgm) == kindHome {
+			prefix = getTopology().Home.Prefix + "/"
 		}
 		qts, err := eos.DumpQuotas(ctx, prefix)
+		cancel()
 		if err != nil {
 			er(err)
 		}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	accountingCmd.AddCommand(accountingBillCmd)
+
+	accountingBillCmd.Flags().StringP("month", "m", "", "month to bill (YYYY-MM)")
+	accountingBillCmd.Flags().String("source", "history", "daily samples source: history (accounting_history_db) or eos (acc-receiver JSON files saved with --push-eos)")
+	accountingBillCmd.Flags().StringP("out", "o", ".", "directory to output the billing statement")
+	accountingBillCmd.Flags().Float64P("cost", "", 2.20, "cost factor for CHF/TBMonth")
+}
+
+// dailyUsage holds the used bytes of one day by charge group and charge role.
+type dailyUsage map[string]map[string]int
+
+type billLine struct {
+	Group      string
+	Role       string
+	ByteMonths float64
+}
+
+func (l *billLine) TBMonths() string {
+	return fmt.Sprintf("%.4f", l.ByteMonths/1000000000000)
+}
+
+var accountingBillCmd = &cobra.Command{
+	Use:   "bill --month <YYYY-MM>",
+	Short: "Computes the monthly CHF/TB-month statement from the daily samples",
+	Run: func(cmd *cobra.Command, args []string) {
+		month, _ := cmd.Flags().GetString("month")
+		source, _ := cmd.Flags().GetString("source")
+		out, _ := cmd.Flags().GetString("out")
+		factorPerTB, _ := cmd.Flags().GetFloat64("cost")
+		var factorPerByte float64 = factorPerTB / float64(1000000000000)
+
+		start, err := time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			exit(cmd)
+		}
+		tableOutputOnly(cmd)
+		end := start.AddDate(0, 1, -1)
+
+		var samples map[string]dailyUsage
+		switch source {
+		case "history":
+			samples = getDailyUsageFromHistory(start, end)
+		case "eos":
+			samples = getDailyUsageFromEOS(start, end)
+		default:
+			exit(cmd)
+		}
+
+		if len(samples) == 0 {
+			er(fmt.Sprintf("no daily samples found for %s in %s", month, source))
+		}
+
+		lines := integrateUsage(samples, start, end)
+		groups := billGroups(lines)
+
+		file := path.Join(out, fmt.Sprintf("accounting-bill-%s-roles.csv", month))
+		saveBillCSV(file, lines, factorPerByte, true)
+		fmt.Printf("%s\n", file)
+
+		file = path.Join(out, fmt.Sprintf("accounting-bill-%s-groups.csv", month))
+		saveBillCSV(file, groups, factorPerByte, false)
+		fmt.Printf("%s\n", file)
+
+		file = path.Join(out, fmt.Sprintf("accounting-bill-%s.html", month))
+		saveBillHTML(file, month, len(samples), end.Day(), lines, groups, factorPerTB, factorPerByte)
+		fmt.Printf("%s\n", file)
+	},
+}
+
+// getDailyUsageFromHistory groups the stored report runs of each day like computeAggregate does.
+var getDailyUsageFromHistory = func(start, end time.Time) map[string]dailyUsage {
+	days, records := loadAccountingHistory(start, end)
+	samples := map[string]dailyUsage{}
+	for _, day := range days {
+		infos := make([]*projectInfo, 0, len(records[day]))
+		for _, r := range records[day] {
+			ui := newUserInfo()
+			ui.Account = r.Account
+			infos = append(infos, &projectInfo{
+				FileInfo:   &eosclient.FileInfo{File: r.Path, Instance: r.Instance},
+				userInfo:   ui,
+				QuotaInfo:  &eosclient.QuotaInfo{AvailableBytes: r.MaxBytes, UsedBytes: r.UsedBytes},
+				chargeInfo: chargeInfo{Type: r.ChargeType, ChargeGroup: r.ChargeGroup, ChargeRole: r.ChargeRole},
+			})
+		}
+
+		usage := dailyUsage{}
+		for group, roles := range aggregateByGroupAndRole(uniqueInfos(infos)) {
+			usage[group] = map[string]int{}
+			for role, quota := range roles {
+				usage[group][role] = quota.UsedBytes
+			}
+		}
+		samples[day] = usage
+	}
+	return samples
+}
+
+// getDailyUsageFromEOS reads the acc-receiver payloads stored in EOS by accounting report --push-eos.
+var getDailyUsageFromEOS = func(start, end time.Time) map[string]dailyUsage {
+	client := getEOSForProject("fdo")
+	samples := map[string]dailyUsage{}
+	spin := NewDescriptionSpinStatus("Reading acc-receiver files")
+	spin.Start()
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		file := path.Join(accountingEOSDir(), day.Format("2006/01/02"), "accounting-json-accreceiver.json")
+		spin.UpdateDescription(file)
+
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+		body, err := client.Read(ctx, accountingEOSUser, file)
+		if err != nil {
+			cancel()
+			// no report was pushed that day
+			continue
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		cancel()
+		if err != nil {
+			er(err)
+		}
+
+		payload := &accReceiverJSON_v3_header{}
+		if err := json.Unmarshal(data, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing %s: %+v\n", file, err)
+			continue
+		}
+
+		usage := dailyUsage{}
+		for _, d := range payload.Data {
+			if _, ok := usage[d.ToChargeGroup]; !ok {
+				usage[d.ToChargeGroup] = map[string]int{}
+			}
+			usage[d.ToChargeGroup][d.ToChargeRole] += d.MetricValue
+		}
+		samples[day.Format(dayLayout)] = usage
+	}
+	spin.Done()
+	return samples
+}
+
+// integrateUsage returns the byte-months of every charge group and role:
+// the used bytes of every day of the month are added and divided by the
+// number of days. Days without a sample take the previous sample, the
+// days before the first sample take the first one.
+func integrateUsage(samples map[string]dailyUsage, start, end time.Time) []*billLine {
+	var first dailyUsage
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if s, ok := samples[day.Format(dayLayout)]; ok {
+			first = s
+			break
+		}
+	}
+
+	totals := map[string]map[string]float64{}
+	days := float64(end.Day())
+	current := first
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if s, ok := samples[day.Format(dayLayout)]; ok {
+			current = s
+		}
+		for group, roles := range current {
+			if _, ok := totals[group]; !ok {
+				totals[group] = map[string]float64{}
+			}
+			for role, used := range roles {
+				totals[group][role] += float64(used) / days
+			}
+		}
+	}
+
+	lines := []*billLine{}
+	for group, roles := range totals {
+		for role, byteMonths := range roles {
+			lines = append(lines, &billLine{Group: group, Role: role, ByteMonths: byteMonths})
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Group != lines[j].Group {
+			return lines[i].Group < lines[j].Group
+		}
+		return lines[i].Role < lines[j].Role
+	})
+	return lines
+}
+
+// billGroups sums the lines of each charge group, sorted by charge group.
+func billGroups(lines []*billLine) []*billLine {
+	groups := []*billLine{}
+	for _, l := range lines {
+		if len(groups) == 0 || groups[len(groups)-1].Group != l.Group {
+			groups = append(groups, &billLine{Group: l.Group})
+		}
+		groups[len(groups)-1].ByteMonths += l.ByteMonths
+	}
+	return groups
+}
+
+func billCost(byteMonths float64, costFactor float64) string {
+	return getCost(int(math.Round(byteMonths)), costFactor)
+}
+
+func saveBillCSV(file string, lines []*billLine, costFactor float64, withRole bool) {
+	os.MkdirAll(path.Dir(file), 0755)
+	fd, err := os.Create(file)
+	if err != nil {
+		er(err)
+	}
+	defer fd.Close()
+
+	w := csv.NewWriter(fd)
+	cols := []string{"CHARGEGROUP", "BYTEMONTHS", "TBMONTHS", "COSTH"}
+	if withRole {
+		cols = []string{"CHARGEGROUP", "CHARGEROLE", "BYTEMONTHS", "TBMONTHS", "COSTH"}
+	}
+	w.Write(cols)
+	for _, l := range lines {
+		row := []string{l.Group}
+		if withRole {
+			row = append(row, l.Role)
+		}
+		row = append(row, fmt.Sprintf("%.0f", l.ByteMonths), l.TBMonths(), billCost(l.ByteMonths, costFactor))
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		er(err)
+	}
+}
+
+var billTemplate = template.Must(template.New("bill").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CERNBox billing statement {{.Month}}</title>
+<style>
+body { font-family: sans-serif; font-size: 11pt; margin: 2cm; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; page-break-inside: avoid; }
+th, td { border-bottom: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.num, th.num { text-align: right; }
+tr.total td { font-weight: bold; border-top: 2px solid #000; }
+@page { size: A4; margin: 1.5cm; }
+</style>
+</head>
+<body>
+<h1>CERNBox billing statement {{.Month}}</h1>
+<p>{{.Rate}} CHF per TB-month, integrated over {{.Days}} days from {{.Samples}} daily samples.</p>
+<h2>Charge groups</h2>
+<table>
+<tr><th>Charge group</th><th class="num">TB-months</th><th class="num">Cost</th></tr>
+{{range .Groups}}<tr><td>{{.Group}}</td><td class="num">{{.TBMonths}}</td><td class="num">{{.Cost}}</td></tr>
+{{end}}<tr class="total"><td>Total</td><td class="num">{{.Total.TBMonths}}</td><td class="num">{{.Total.Cost}}</td></tr>
+</table>
+<h2>Charge roles</h2>
+{{range .Groups}}<table>
+<tr><th>{{.Group}}</th><th class="num">TB-months</th><th class="num">Cost</th></tr>
+{{range .Roles}}<tr><td>{{.Role}}</td><td class="num">{{.TBMonths}}</td><td class="num">{{.Cost}}</td></tr>
+{{end}}<tr class="total"><td>Total {{.Group}}</td><td class="num">{{.TBMonths}}</td><td class="num">{{.Cost}}</td></tr>
+</table>
+{{end}}</body>
+</html>
+`))
+
+func saveBillHTML(file, month string, samples, days int, lines, groups []*billLine, rate, costFactor float64) {
+	type htmlLine struct {
+		Group, Role, TBMonths, Cost string
+		Roles                       []*htmlLine
+	}
+	toHTML := func(l *billLine) *htmlLine {
+		return &htmlLine{Group: l.Group, Role: l.Role, TBMonths: l.TBMonths(), Cost: billCost(l.ByteMonths, costFactor)}
+	}
+
+	total := &billLine{}
+	hgroups := []*htmlLine{}
+	for _, g := range groups {
+		hg := toHTML(g)
+		for _, l := range lines {
+			if l.Group == g.Group {
+				hg.Roles = append(hg.Roles, toHTML(l))
+			}
+		}
+		hgroups = append(hgroups, hg)
+		total.ByteMonths += g.ByteMonths
+	}
+
+	data := map[string]interface{}{
+		"Month":   month,
+		"Rate":    fmt.Sprintf("%.2f", rate),
+		"Days":    days,
+		"Samples": samples,
+		"Groups":  hgroups,
+		"Total":   toHTML(total),
+	}
+
+	os.MkdirAll(path.Dir(file), 0755)
+	fd, err := os.Create(file)
+	if err != nil {
+		er(err)
+	}
+	defer fd.Close()
+	if err := billTemplate.Execute(fd, data); err != nil {
+		er(err)
+	}
+}
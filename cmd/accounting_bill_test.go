@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIntegrateUsage(t *testing.T) {
+	start := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, -1)
+
+	// the days 1-2 take the sample of day 3, the days 4-10 keep it
+	// and the days 12-30 keep the sample of day 11
+	samples := map[string]dailyUsage{
+		"2026-04-03": {"IT": {"Home": 300}},
+		"2026-04-11": {"IT": {"Home": 600}, "EP": {"Project": 30}},
+	}
+
+	tuples := map[string]float64{
+		"EP/Project": 20,
+		"IT/Home":    500,
+	}
+
+	lines := integrateUsage(samples, start, end)
+	if len(lines) != len(tuples) {
+		t.Fatalf("got:%d expected:%d lines", len(lines), len(tuples))
+	}
+	for _, l := range lines {
+		key := l.Group + "/" + l.Role
+		expected, ok := tuples[key]
+		if !ok {
+			t.Fatalf("unexpected line %s", key)
+		}
+		if got := fmt.Sprintf("%.3f", l.ByteMonths); got != fmt.Sprintf("%.3f", expected) {
+			t.Fatalf("%s got:%s expected:%.3f", key, got, expected)
+		}
+	}
+
+	if lines := integrateUsage(map[string]dailyUsage{}, start, end); len(lines) != 0 {
+		t.Fatalf("got:%d expected:0 lines without samples", len(lines))
+	}
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The accounting history is a bbolt database with one bucket per day
+// ("2006-01-02") nested in the "Accounting" bucket. Each day bucket
+// holds one historyRecord per path, keyed by the path.
+//
+// EX.
+// "2020-11-09" -> "/eos/project/c/cernbox" -> {UsedBytes: 10, ChargeGroup: "IT", ...}
+
+const accountingHistoryBucket = "Accounting"
+const dayLayout = "2006-01-02"
+
+func init() {
+	accountingCmd.AddCommand(accountingHistoryCmd)
+
+	accountingHistoryCmd.Flags().String("from", "", "first day of the window (YYYY-MM-DD), defaults to 30 days before --to")
+	accountingHistoryCmd.Flags().String("to", "", "last day of the window (YYYY-MM-DD), defaults to today")
+	accountingHistoryCmd.Flags().String("by", "project", "aggregate growth by project, group or role")
+	accountingHistoryCmd.Flags().IntP("top", "n", 10, "flag the <n> fastest growers")
+}
+
+type historyRecord struct {
+	Path        string `json:"path"`
+	Instance    string `json:"instance"`
+	Account     string `json:"account"`
+	AccountType string `json:"account_type"`
+	MaxBytes    int    `json:"max_bytes"`
+	UsedBytes   int    `json:"used_bytes"`
+	ChargeType  string `json:"charge_type"`
+	ChargeGroup string `json:"charge_group"`
+	ChargeRole  string `json:"charge_role"`
+}
+
+func getAccountingHistoryDB() string {
+	return viper.GetString("accounting_history_db")
+}
+
+func openAccountingHistory() *bolt.DB {
+	file := getAccountingHistoryDB()
+	if file == "" {
+		er("please set accounting_history_db in the config")
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second * 30})
+	if err != nil {
+		er(err)
+	}
+	return db
+}
+
+// saveAccountingHistory stores the rows of a report run under the given day,
+// replacing a previous run of the same day.
+var saveAccountingHistory = func(infos []*projectInfo, day time.Time) {
+	db := openAccountingHistory()
+	defer db.Close()
+
+	key := day.Format(dayLayout)
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(accountingHistoryBucket))
+		if err != nil {
+			return err
+		}
+		if root.Bucket([]byte(key)) != nil {
+			if err := root.DeleteBucket([]byte(key)); err != nil {
+				return err
+			}
+		}
+		bucket, err := root.CreateBucket([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			r := &historyRecord{
+				Path:        info.FileInfo.File,
+				Instance:    info.FileInfo.Instance,
+				Account:     info.userInfo.Account,
+				AccountType: info.userInfo.accountTypeHuman(),
+				MaxBytes:    info.QuotaInfo.AvailableBytes,
+				UsedBytes:   info.QuotaInfo.UsedBytes,
+				ChargeType:  info.chargeInfo.Type,
+				ChargeGroup: info.chargeInfo.ChargeGroup,
+				ChargeRole:  info.chargeInfo.ChargeRole,
+			}
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(r.Path), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		er(err)
+	}
+}
+
+// loadAccountingHistory returns the stored days within [from, to] in
+// chronological order with the records of each day.
+var loadAccountingHistory = func(from, to time.Time) ([]string, map[string][]*historyRecord) {
+	db := openAccountingHistory()
+	defer db.Close()
+
+	first, last := from.Format(dayLayout), to.Format(dayLayout)
+	days := []string{}
+	records := map[string][]*historyRecord{}
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(accountingHistoryBucket))
+		if root == nil {
+			return nil
+		}
+		// day keys sort lexicographically in chronological order
+		c := root.Cursor()
+		for k, _ := c.Seek([]byte(first)); k != nil && string(k) <= last; k, _ = c.Next() {
+			bucket := root.Bucket(k)
+			if bucket == nil {
+				continue
+			}
+			day := string(k)
+			err := bucket.ForEach(func(_, v []byte) error {
+				r := &historyRecord{}
+				if err := json.Unmarshal(v, r); err != nil {
+					return err
+				}
+				records[day] = append(records[day], r)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			days = append(days, day)
+		}
+		return nil
+	})
+	if err != nil {
+		er(err)
+	}
+	return days, records
+}
+
+// historyKey returns the aggregation key of the record for the given dimension.
+func historyKey(r *historyRecord, by string) string {
+	switch by {
+	case "group":
+		return r.ChargeGroup
+	case "role":
+		return r.ChargeRole
+	default:
+		return r.Path
+	}
+}
+
+// aggregateHistory sums the used bytes of the records by the given dimension.
+// Quota is accounted per account and instance, so for groups and roles the
+// paths with the same account and instance are only counted once.
+func aggregateHistory(records []*historyRecord, by string) map[string]int {
+	agg := map[string]int{}
+	seen := map[string]bool{}
+	for _, r := range records {
+		if by == "group" || by == "role" {
+			quotaNode := fmt.Sprintf("%s-%s", r.Account, r.Instance)
+			if seen[quotaNode] {
+				continue
+			}
+			seen[quotaNode] = true
+		}
+		agg[historyKey(r, by)] += r.UsedBytes
+	}
+	return agg
+}
+
+func parseDay(s string, def time.Time) time.Time {
+	if s == "" {
+		return def
+	}
+	t, err := time.ParseInLocation(dayLayout, s, time.Local)
+	if err != nil {
+		er(err)
+	}
+	return t
+}
+
+var accountingHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Shows storage growth over a window using the stored report runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		fromString, _ := cmd.Flags().GetString("from")
+		toString, _ := cmd.Flags().GetString("to")
+		by, _ := cmd.Flags().GetString("by")
+		top, _ := cmd.Flags().GetInt("top")
+
+		if by != "project" && by != "group" && by != "role" {
+			exit(cmd)
+		}
+
+		to := parseDay(toString, time.Now().Local())
+		from := parseDay(fromString, to.AddDate(0, 0, -30))
+
+		days, records := loadAccountingHistory(from, to)
+		if len(days) < 2 {
+			er(fmt.Sprintf("at least two report runs are needed between %s and %s, found %d", from.Format(dayLayout), to.Format(dayLayout), len(days)))
+		}
+
+		firstDay, lastDay := days[0], days[len(days)-1]
+		start := aggregateHistory(records[firstDay], by)
+		end := aggregateHistory(records[lastDay], by)
+
+		type growth struct {
+			key        string
+			start, end int
+		}
+		growths := []*growth{}
+		keys := map[string]bool{}
+		for k := range start {
+			keys[k] = true
+		}
+		for k := range end {
+			keys[k] = true
+		}
+		for k := range keys {
+			growths = append(growths, &growth{key: k, start: start[k], end: end[k]})
+		}
+		sort.Slice(growths, func(i, j int) bool {
+			gi, gj := growths[i].end-growths[i].start, growths[j].end-growths[j].start
+			if gi != gj {
+				return gi > gj
+			}
+			return growths[i].key < growths[j].key
+		})
+
+		cols := []string{"KEY", "START", "END", "STARTUSEDBYTES", "ENDUSEDBYTES", "GROWTHBYTES", "GROWTHH", "GROWTHPCT", "FASTEST"}
+		rows := [][]string{}
+		for i, g := range growths {
+			diff := g.end - g.start
+			pct := "-"
+			if g.start > 0 {
+				pct = fmt.Sprintf("%.2f%%", float64(diff)*100/float64(g.start))
+			}
+			sign := ""
+			if diff < 0 {
+				sign = "-"
+			}
+			fastest := ""
+			if i < top && diff > 0 {
+				fastest = "*"
+			}
+			rows = append(rows, []string{
+				g.key,
+				firstDay,
+				lastDay,
+				fmt.Sprintf("%d", g.start),
+				fmt.Sprintf("%d", g.end),
+				fmt.Sprintf("%d", diff),
+				sign + humanQuota(int(math.Abs(float64(diff)))),
+				pct,
+				fastest,
+			})
+		}
+		pretty(cols, rows)
+	},
+}
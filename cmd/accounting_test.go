@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+)
+
+func TestComputeAggregate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cernboxcop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "accounting-agg.txt")
+
+	newInfo := func(account, group, role string, max, used int) *projectInfo {
+		return &projectInfo{
+			userInfo:   &userInfo{Account: account},
+			QuotaInfo:  &eosclient.QuotaInfo{AvailableBytes: max, UsedBytes: used},
+			chargeInfo: chargeInfo{ChargeGroup: group, ChargeRole: role},
+		}
+	}
+	infos := []*projectInfo{
+		newInfo("gonzalhu", "IT", "Home", 100, 10),
+		newInfo("labkode", "IT", "Home", 200, 20),
+		// the same quota node listed twice is only counted once
+		newInfo("cboxsvc", "IT", "Project", 1000, 100),
+		newInfo("cboxsvc", "IT", "Project", 1000, 100),
+		newInfo("atlassvc", "EP", "Project", 50, 5),
+	}
+	computeAggregate(infos, file, 1)
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// group/role => max used
+	tuples := map[string]string{
+		"EP/Project": "50 5",
+		"IT/Home":    "300 30",
+		"IT/Project": "1000 100",
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")[1:]
+	if len(lines) != len(tuples) {
+		t.Fatalf("got:%d expected:%d rows", len(lines), len(tuples))
+	}
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		key := fields[5] + "/" + fields[6]
+		expected, ok := tuples[key]
+		if !ok {
+			t.Fatalf("unexpected row %s", line)
+		}
+		if got := fields[0] + " " + fields[1]; got != expected {
+			t.Fatalf("got:%s expected:%s", got, expected)
+		}
+	}
+}
+
+func TestComputeAggregateReceiverJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cernboxcop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "accounting-receiver.json")
+
+	newInfo := func(account, group, role string, max, used int) *projectInfo {
+		return &projectInfo{
+			userInfo:   &userInfo{Account: account},
+			QuotaInfo:  &eosclient.QuotaInfo{AvailableBytes: max, UsedBytes: used},
+			chargeInfo: chargeInfo{ChargeGroup: group, ChargeRole: role},
+		}
+	}
+	infos := []*projectInfo{
+		// two projects charged to the same group and role
+		newInfo("cboxsvc", "IT", "Project", 1000, 100),
+		newInfo("docsvc", "IT", "Project", 500, 50),
+		newInfo("cboxsvc", "IT", "Project", 1000, 100),
+		newInfo("atlassvc", "EP", "Project", 50, 5),
+	}
+	computeAggregateReceiverJSON(infos, file, false)
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := &accReceiverJSON_v3_header{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// group/role => used
+	tuples := map[string]string{
+		"EP/Project": "5",
+		"IT/Project": "150",
+	}
+	if len(payload.Data) != len(tuples) {
+		t.Fatalf("got:%d expected:%d entries", len(payload.Data), len(tuples))
+	}
+	for _, d := range payload.Data {
+		key := d.ToChargeGroup + "/" + d.ToChargeRole
+		if got := fmt.Sprintf("%d", d.MetricValue); got != tuples[key] {
+			t.Fatalf("got:%s expected:%s", got, tuples[key])
+		}
+	}
+}
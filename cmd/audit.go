@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The audit log is a file with one JSON entry per line recording the
+// changes done by the operators, set with audit_log in the config.
+//
+// EX.
+// {"time":"2020-11-09T15:07:28+01:00","operator":"gonzalhu","action":"sharing-create","details":{"id":"1234","path":"/eos/user/g/gonzalhu/docs"}}
+
+type auditEntry struct {
+	Time     time.Time         `json:"time"`
+	Operator string            `json:"operator"`
+	Action   string            `json:"action"`
+	Details  map[string]string `json:"details"`
+}
+
+func getAuditLog() string {
+	return viper.GetString("audit_log")
+}
+
+// checkAuditLog exits if the audit log is not configured, to be called before changing anything.
+func checkAuditLog() {
+	if getAuditLog() == "" {
+		er("please set audit_log in the config")
+	}
+}
+
+// auditLog appends an entry for the action to the audit log.
+func auditLog(action string, details map[string]string) {
+	checkAuditLog()
+
+	operator := os.Getenv("SUDO_USER")
+	if operator == "" {
+		if u, err := user.Current(); err == nil {
+			operator = u.Username
+		}
+	}
+
+	data, err := json.Marshal(&auditEntry{Time: time.Now(), Operator: operator, Action: action, Details: details})
+	if err != nil {
+		er(err)
+	}
+
+	fd, err := os.OpenFile(getAuditLog(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		er(err)
+	}
+	defer fd.Close()
+	if _, err := fd.Write(append(data, '\n')); err != nil {
+		er(err)
+	}
+}
@@ -36,9 +36,10 @@ var eosQuotaCmd = &cobra.Command{
 }
 
 func getEosQuotaForUser(username string) *eosclient.QuotaInfo {
-	ctx, _ := context.WithTimeout(getCtx(), time.Second*60)
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+	defer cancel()
 	eos := getEOSForUser(username)
-	quota, err := eos.GetQuota(ctx, username, "/eos/user/")
+	quota, err := eos.GetQuota(ctx, username, getTopology().Home.Prefix+"/")
 	if err != nil {
 		er(err)
 	}
@@ -46,9 +47,10 @@ func getEosQuotaForUser(username string) *eosclient.QuotaInfo {
 }
 
 func getEosQuota(mgm, username string) *eosclient.QuotaInfo {
-	ctx, _ := context.WithTimeout(getCtx(), time.Second*60)
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+	defer cancel()
 	eos := getEOS(mgm)
-	quota, err := eos.GetQuota(ctx, username, "/eos/user/")
+	quota, err := eos.GetQuota(ctx, username, getTopology().Home.Prefix+"/")
 	if err != nil {
 		er(err)
 	}
@@ -56,21 +58,23 @@ func getEosQuota(mgm, username string) *eosclient.QuotaInfo {
 }
 
 func getEOSQuota(mgm string, uid uint64) *eosclient.QuotaInfo {
-	ctx, _ := context.WithTimeout(getCtx(), time.Second*10)
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*10)
+	defer cancel()
 	eos := getEOS(mgm)
 	username := fmt.Sprintf("%d", uid)
-	quota, err := eos.GetQuota(ctx, username, "/eos/user/")
+	quota, err := eos.GetQuota(ctx, username, getTopology().Home.Prefix+"/")
 	if err != nil {
 		er(err)
 	}
 	return quota
 }
 
-func getEOSProjectQuota(mgm string, uid uint64) *eosclient.QuotaInfo {
-	ctx, _ := context.WithTimeout(getCtx(), time.Second*60)
+// getEOSProjectQuota returns the quota of the service account on the project quota node.
+func getEOSProjectQuota(mgm, username string) *eosclient.QuotaInfo {
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+	defer cancel()
 	eos := getEOS(mgm)
-	username := fmt.Sprintf("%d", uid)
-	quota, err := eos.GetQuota(ctx, username, "/eos/project/")
+	quota, err := eos.GetQuota(ctx, username, getTopology().Project.Prefix+"/")
 	if err != nil {
 		er(err)
 	}
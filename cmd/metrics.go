@@ -234,7 +234,7 @@ var availabilityCmd = &cobra.Command{
 
 func aclTest(node, user, password string, e *error, wg *sync.WaitGroup) {
 	defer wg.Done()
-	eosClient := getEOS(fmt.Sprintf("root://%s.cern.ch", node))
+	eosClient := getEOS(getTopology().mgmForInstance(node))
 	path := fmt.Sprintf("/eos/%s/opstest/sls", getFolderNameFromNode(node))
 
 	ctx := getCtx()
@@ -251,7 +251,8 @@ func eosFuseTest(path, user, password string, e *error, wg *sync.WaitGroup) {
 
 	cmd := "ls " + path
 	cmdBash := exec.Command("/usr/bin/bash", "-c", cmd)
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*30)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
 	_, _, *e = execute(ctx, cmdBash)
 }
 
@@ -266,7 +267,7 @@ func getFolderNameFromNode(node string) string {
 
 func xrdcpTest(node, user, password string, e *error, wg *sync.WaitGroup) {
 	defer wg.Done()
-	eosClient := getEOS(fmt.Sprintf("root://%s.cern.ch", node))
+	eosClient := getEOS(getTopology().mgmForInstance(node))
 
 	text := "dummy text with time " + time.Now().String()
 	reader := strings.NewReader(text)
@@ -297,10 +298,6 @@ var ioStatCmd = &cobra.Command{
 	Use:   "eos-io-stat",
 	Short: "Retrieves IO operation calls per user",
 	Run: func(cmd *cobra.Command, args []string) {
-		influxUsername := viper.GetString("influx_username")
-		influxPassword := viper.GetString("influx_password")
-		influxHostname := viper.GetString("influx_hostname")
-		influxPort := viper.GetInt("influx_port")
 		type fourTuple struct {
 			instance string
 			username string
@@ -308,16 +305,16 @@ var ioStatCmd = &cobra.Command{
 			value    float64
 		}
 		tuples := []*fourTuple{}
-		mgms := []string{"eoshome-i00", "eoshome-i01", "eoshome-i02", "eoshome-i03", "eoshome-i04", "eosproject-i00", "eosproject-i01", "eosproject-i02"}
-		for _, i := range mgms {
+		for _, inst := range getTopology().instances("") {
+			i := inst.Name
 			a := `eos -r 0 0 io stat -a -m | grep uid | less | sed 's/=/ /g' | awk '{print $2, $4, $12}'`
 			c := exec.Command("/usr/bin/bash", "-c", a)
-			m := fmt.Sprintf("root://%s.cern.ch", i)
 			c.Env = []string{
-				"EOS_MGM_URL=" + m,
+				"EOS_MGM_URL=" + inst.MGM,
 			}
-			ctx, _ := context.WithTimeout(context.Background(), time.Second*30)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 			o, e, err := execute(ctx, c)
+			cancel()
 			if err != nil {
 				fmt.Fprintln(os.Stdout, "stdout", o)
 				fmt.Fprintln(os.Stderr, "stderr", e)
@@ -356,29 +353,7 @@ var ioStatCmd = &cobra.Command{
 			lines = append(lines, line)
 		}
 
-		client := &http.Client{}
-		limit := len(lines) / 2000 // 2K chunks are under 5K batch size recommendation for influx
-		for j := 0; j < len(lines); j += limit {
-			batch := lines[j:min(j+limit, len(lines))]
-			body := strings.Join(batch, "\n")
-			url := fmt.Sprintf("https://%s:%d/write?db=eos", influxHostname, influxPort)
-			req, err := http.NewRequest("POST", url, strings.NewReader(body))
-			if err != nil {
-				er(err)
-			}
-			req.SetBasicAuth(influxUsername, influxPassword)
-			res, err := client.Do(req)
-			if err != nil {
-				er(err)
-			}
-
-			if res.StatusCode != http.StatusNoContent {
-				body, _ := ioutil.ReadAll(res.Body)
-				errString := fmt.Sprintf("failed to write to influxdb: %v %v", res.StatusCode, string(body))
-				err := errors.New(errString)
-				er(err)
-			}
-		}
+		writeInflux(lines)
 
 	},
 }
@@ -387,10 +362,6 @@ var quotaCmd = &cobra.Command{
 	Use:   "eos-quota",
 	Short: "Retrieves quotas",
 	Run: func(cmd *cobra.Command, args []string) {
-		influxUsername := viper.GetString("influx_username")
-		influxPassword := viper.GetString("influx_password")
-		influxHostname := viper.GetString("influx_hostname")
-		influxPort := viper.GetInt("influx_port")
 		type tuple struct {
 			instance string
 			username string
@@ -398,16 +369,16 @@ var quotaCmd = &cobra.Command{
 			value    float64
 		}
 		tuples := []*tuple{}
-		mgms := []string{"eoshome-i00", "eoshome-i01", "eoshome-i02", "eoshome-i03", "eoshome-i04", "eosproject-i00", "eosproject-i01", "eosproject-i02"}
-		for _, i := range mgms {
+		for _, inst := range getTopology().instances("") {
+			i := inst.Name
 			a := `eos -r 0 0 quota ls -m | sed 's/=/ /g' |  awk '{print $4,$6,$10,$12,$16,$18}'`
 			c := exec.Command("/usr/bin/bash", "-c", a)
-			m := fmt.Sprintf("root://%s.cern.ch", i)
 			c.Env = []string{
-				"EOS_MGM_URL=" + m,
+				"EOS_MGM_URL=" + inst.MGM,
 			}
-			ctx, _ := context.WithTimeout(context.Background(), time.Second*30)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 			o, e, err := execute(ctx, c)
+			cancel()
 			if err != nil {
 				fmt.Fprintln(os.Stdout, "stdout", o)
 				fmt.Fprintln(os.Stderr, "stderr", e)
@@ -433,7 +404,7 @@ var quotaCmd = &cobra.Command{
 				maxBytesString := tokens[4]
 				maxFilesString := tokens[5]
 
-				if !strings.HasPrefix(space, "/eos/user") && !strings.HasPrefix(space, "/eos/project") {
+				if !strings.HasPrefix(space, getTopology().Home.Prefix) && !strings.HasPrefix(space, getTopology().Project.Prefix) {
 					continue
 				}
 
@@ -476,29 +447,7 @@ var quotaCmd = &cobra.Command{
 			lines = append(lines, line)
 		}
 
-		client := &http.Client{}
-		limit := len(lines) / 2000 // 2K chunks are under 5K batch size recommendation for influx
-		for j := 0; j < len(lines); j += limit {
-			batch := lines[j:min(j+limit, len(lines))]
-			body := strings.Join(batch, "\n")
-			url := fmt.Sprintf("https://%s:%d/write?db=eos", influxHostname, influxPort)
-			req, err := http.NewRequest("POST", url, strings.NewReader(body))
-			if err != nil {
-				er(err)
-			}
-			req.SetBasicAuth(influxUsername, influxPassword)
-			res, err := client.Do(req)
-			if err != nil {
-				er(err)
-			}
-
-			if res.StatusCode != http.StatusNoContent {
-				body, _ := ioutil.ReadAll(res.Body)
-				errString := fmt.Sprintf("failed to write to influxdb: %v %v", res.StatusCode, string(body))
-				err := errors.New(errString)
-				er(err)
-			}
-		}
+		writeInflux(lines)
 
 	},
 }
@@ -507,10 +456,6 @@ var nsStatCmd = &cobra.Command{
 	Use:   "eos-ns-stat",
 	Short: "Retrieves NS operation calls per user",
 	Run: func(cmd *cobra.Command, args []string) {
-		influxUsername := viper.GetString("influx_username")
-		influxPassword := viper.GetString("influx_password")
-		influxHostname := viper.GetString("influx_hostname")
-		influxPort := viper.GetInt("influx_port")
 		type fourTuple struct {
 			instance string
 			username string
@@ -518,16 +463,16 @@ var nsStatCmd = &cobra.Command{
 			value    float64
 		}
 		tuples := []*fourTuple{}
-		mgms := []string{"eoshome-i00", "eoshome-i01", "eoshome-i02", "eoshome-i03", "eoshome-i04", "eosproject-i00", "eosproject-i01", "eosproject-i02"}
-		for _, i := range mgms {
+		for _, inst := range getTopology().instances("") {
+			i := inst.Name
 			a := `eos -r 0 0 ns stat -m -a | grep cmd | sed 's/=/ /g' | grep -v 'root cmd' | sed 's/gid all //g' | awk '{print $2,$4,$14}'`
 			c := exec.Command("/usr/bin/bash", "-c", a)
-			m := fmt.Sprintf("root://%s.cern.ch", i)
 			c.Env = []string{
-				"EOS_MGM_URL=" + m,
+				"EOS_MGM_URL=" + inst.MGM,
 			}
-			ctx, _ := context.WithTimeout(context.Background(), time.Second*30)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 			o, e, err := execute(ctx, c)
+			cancel()
 			if err != nil {
 				fmt.Fprintln(os.Stdout, "stdout", o)
 				fmt.Fprintln(os.Stderr, "stderr", e)
@@ -570,31 +515,44 @@ var nsStatCmd = &cobra.Command{
 			lines = append(lines, line)
 		}
 
-		client := &http.Client{}
-		limit := len(lines) / 2000 // 2K chunks are under 5K batch size recommendation for influx
-		for j := 0; j < len(lines); j += limit {
-			batch := lines[j:min(j+limit, len(lines))]
-			body := strings.Join(batch, "\n")
-			url := fmt.Sprintf("https://%s:%d/write?db=eos", influxHostname, influxPort)
-			req, err := http.NewRequest("POST", url, strings.NewReader(body))
-			if err != nil {
-				er(err)
-			}
-			req.SetBasicAuth(influxUsername, influxPassword)
-			res, err := client.Do(req)
-			if err != nil {
-				er(err)
-			}
+		writeInflux(lines)
 
-			if res.StatusCode != http.StatusNoContent {
-				body, _ := ioutil.ReadAll(res.Body)
-				errString := fmt.Sprintf("failed to write to influxdb: %v %v", res.StatusCode, string(body))
-				err := errors.New(errString)
-				er(err)
-			}
+	},
+}
+
+// influxBatchSize keeps the writes under the 5K points batch size recommendation for influx.
+const influxBatchSize = 2000
+
+// writeInflux writes the line-protocol points to the eos database in batches.
+func writeInflux(lines []string) {
+	influxUsername := viper.GetString("influx_username")
+	influxPassword := viper.GetString("influx_password")
+	influxHostname := viper.GetString("influx_hostname")
+	influxPort := viper.GetInt("influx_port")
+
+	client := &http.Client{}
+	url := fmt.Sprintf("https://%s:%d/write?db=eos", influxHostname, influxPort)
+	for j := 0; j < len(lines); j += influxBatchSize {
+		batch := lines[j:min(j+influxBatchSize, len(lines))]
+		body := strings.Join(batch, "\n")
+		req, err := http.NewRequest("POST", url, strings.NewReader(body))
+		if err != nil {
+			er(err)
+		}
+		req.SetBasicAuth(influxUsername, influxPassword)
+		res, err := client.Do(req)
+		if err != nil {
+			er(err)
 		}
 
-	},
+		if res.StatusCode != http.StatusNoContent {
+			body, _ := ioutil.ReadAll(res.Body)
+			errString := fmt.Sprintf("failed to write to influxdb: %v %v", res.StatusCode, string(body))
+			err := errors.New(errString)
+			er(err)
+		}
+		res.Body.Close()
+	}
 }
 
 func execute(ctx context.Context, cmd *exec.Cmd) (string, string, error) {
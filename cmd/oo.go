@@ -126,7 +126,6 @@ var ooCmd = &cobra.Command{
 			}
 
 			instance := tokens[0]
-			instance = strings.ReplaceAll(instance, "newproject", "eosproject")
 			inodeString := tokens[1]
 			inode, err := strconv.ParseUint(inodeString, 10, 64)
 			if err != nil {
@@ -134,10 +133,10 @@ var ooCmd = &cobra.Command{
 				continue
 			}
 
-			mgm := fmt.Sprintf("root://%s.cern.ch", instance)
-			client := getEOS(mgm)
-			ctx, _ := context.WithTimeout(context.Background(), time.Second*10)
+			client := getEOS(getTopology().mgmForInstance(instance))
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 			fi, err := client.GetFileInfoByInode(ctx, "root", inode)
+			cancel()
 			if err != nil {
 				k := fmt.Sprintf("forgotten=%t line=%s", forgotten[key], line)
 				invalidEos = append(invalidEos, k)
@@ -242,6 +241,10 @@ var ooCmd = &cobra.Command{
 			// we need to strip the path to get the relative part
 			p = strings.TrimPrefix(p, "/index.php/apps/onlyoffice/storage/track/")
 			eosInfo, err := demangle(p, token)
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", l, err))
+				continue
+			}
 			eosPaths[eosInfo] = true
 
 		}
@@ -337,7 +340,7 @@ func demangle(p, t string) (string, error) {
 	var eospath string
 	if strings.HasPrefix(p, "__myprojects") {
 		eospath = strings.Split(p, "__myprojects/")[1]
-		eospath = fmt.Sprintf("/eos/project/%s/%s", string(eospath[0]), eospath)
+		eospath = path.Join(getTopology().Project.Prefix, string(eospath[0]), eospath)
 	} else if strings.HasPrefix(p, "__myshares/") {
 		eospath = strings.TrimPrefix(p, "__myshares/")
 		tokens := strings.Split(eospath, "/")
@@ -353,8 +356,12 @@ func demangle(p, t string) (string, error) {
 		}
 
 		share := shares[0]
+		sharePath, err := share.resolvePath(getCtx())
+		if err != nil {
+			return "", err
+		}
 		filename := path.Join(tokens[1:]...)
-		filename = path.Join(share.GetPath(), filename)
+		filename = path.Join(sharePath, filename)
 		return filename, nil
 	} else {
 		// path is relative to user
@@ -384,13 +391,18 @@ func demangle(p, t string) (string, error) {
 			if err != nil {
 				return "", err
 			}
-			pl := pls[0]
-			return path.Join(pl.GetPath(), p), nil
+			if len(pls) == 0 {
+				return "", errors.New("public link " + jp.Token + " does not exist")
+			}
+			plPath, err := pls[0].resolvePath(getCtx())
+			if err != nil {
+				return "", err
+			}
+			return path.Join(plPath, p), nil
 
 		}
 
-		filename := fmt.Sprintf("/eos/user/%s/%s", string(username[0]), username)
-		filename = path.Join(filename, p)
+		filename := path.Join(getTopology().Home.path(username), p)
 		return filename, nil
 	}
 
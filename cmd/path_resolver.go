@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The path resolver turns the prefix:inode of a share into its EOS path with the
+// fileinfo call of the MGM HTTP port. It is configured in the config file:
+//
+//	path_resolver_max_attempts: 5      # attempts while the MGM does not answer 200
+//	path_resolver_backoff: 100ms       # first backoff, doubled on every attempt
+//	path_resolver_max_backoff: 5s
+//	path_resolver_timeout: 10s         # timeout of every attempt
+//	path_resolver_concurrency: 20      # concurrent lookups per MGM
+//	path_cache_db: /var/tmp/cernboxcop-paths.db  # on-disk cache, memory only if empty
+//	path_cache_ttl: 24h
+//	path_cache_size: 100000            # paths kept in memory
+
+const pathCacheBucket = "Paths"
+
+// errInodeNotFound is returned when the fileinfo answer is empty, usually because
+// the MGM does not know the inode. Callers deleting data must confirm it.
+var errInodeNotFound = errors.New("inode not found")
+
+type pathResolverOptions struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	Concurrency int
+	CacheDB     string
+	CacheTTL    time.Duration
+	CacheSize   int
+}
+
+type pathResolver struct {
+	opts   *pathResolverOptions
+	client *http.Client
+
+	mu     sync.Mutex
+	limits map[string]chan struct{} // per MGM host
+	memory *pathLRU
+	db     *bolt.DB
+}
+
+type pathCacheEntry struct {
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
+}
+
+func getPathResolverOptions() *pathResolverOptions {
+	opts := &pathResolverOptions{
+		MaxAttempts: viper.GetInt("path_resolver_max_attempts"),
+		Backoff:     viper.GetDuration("path_resolver_backoff"),
+		MaxBackoff:  viper.GetDuration("path_resolver_max_backoff"),
+		Timeout:     viper.GetDuration("path_resolver_timeout"),
+		Concurrency: viper.GetInt("path_resolver_concurrency"),
+		CacheDB:     viper.GetString("path_cache_db"),
+		CacheTTL:    viper.GetDuration("path_cache_ttl"),
+		CacheSize:   viper.GetInt("path_cache_size"),
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 20
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = 24 * time.Hour
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = 100000
+	}
+	return opts
+}
+
+func newPathResolver(opts *pathResolverOptions) *pathResolver {
+	r := &pathResolver{
+		opts:   opts,
+		client: &http.Client{},
+		limits: map[string]chan struct{}{},
+		memory: newPathLRU(opts.CacheSize),
+	}
+	if opts.CacheDB != "" {
+		db, err := bolt.Open(opts.CacheDB, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			// another run holds the cache, keep going with the memory one
+			fmt.Fprintf(os.Stderr, "path cache %s not available: %+v\n", opts.CacheDB, err)
+		} else {
+			r.db = db
+		}
+	}
+	return r
+}
+
+var pathResolverInstance *pathResolver
+var pathResolverOnce sync.Once
+
+// getPathResolver returns the path resolver from the config, shared by all the commands.
+func getPathResolver() *pathResolver {
+	pathResolverOnce.Do(func() {
+		pathResolverInstance = newPathResolver(getPathResolverOptions())
+	})
+	return pathResolverInstance
+}
+
+// closePathResolver closes the shared path resolver if it was used.
+func closePathResolver() {
+	if pathResolverInstance != nil {
+		pathResolverInstance.close()
+	}
+}
+
+// close releases the on-disk cache so other runs can open it.
+func (r *pathResolver) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db != nil {
+		r.db.Close()
+		r.db = nil
+	}
+}
+
+// invalidate drops the cached paths at or under the path, to be called after moving it.
+func (r *pathResolver) invalidate(p string) {
+	under := func(cached string) bool {
+		return cached == p || strings.HasPrefix(cached, p+"/")
+	}
+
+	r.mu.Lock()
+	r.memory.removeIf(func(e *pathCacheEntry) bool { return under(e.Path) })
+	db := r.db
+	r.mu.Unlock()
+	if db == nil {
+		return
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(pathCacheBucket))
+		if bucket == nil {
+			return nil
+		}
+		keys := [][]byte{}
+		err := bucket.ForEach(func(k, v []byte) error {
+			e := &pathCacheEntry{}
+			if json.Unmarshal(v, e) == nil && under(e.Path) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error invalidating the cached paths under %s: %+v\n", p, err)
+	}
+}
+
+// resolve returns the path of the inode on the instance of the prefix.
+// The cache is skipped when fresh is set, for callers acting on deleted files.
+func (r *pathResolver) resolve(ctx context.Context, prefix, inode string, fresh bool) (string, error) {
+	ino, err := strconv.ParseUint(inode, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid inode %q", inode)
+	}
+
+	key := fmt.Sprintf("%s:%s", getTopology().canonicalInstance(prefix), inode)
+	if !fresh {
+		if p, ok := r.cached(key); ok {
+			return p, nil
+		}
+	}
+
+	if getStorageDriver() == "fake" {
+		// the fixture has no HTTP endpoint, the inode is looked up through the Storage
+		fi, err := getEOS(getTopology().mgmForInstance(prefix)).GetFileInfoByInode(ctx, "root", ino)
+		if err != nil {
+			if _, ok := err.(errtypes.IsNotFound); ok {
+				return "", errInodeNotFound
+			}
+			return "", err
+		}
+		r.store(key, fi.File)
+		return fi.File, nil
+	}
+
+	mgm := mgmHost(getTopology().mgmForInstance(prefix))
+	limit := r.limit(mgm)
+	select {
+	case limit <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-limit }()
+
+	url := fmt.Sprintf("http://%s:%d/proc/user/?mgm.cmd=fileinfo&mgm.path=inode:%s&mgm.file.info.option=--path&mgm.format=fuse", mgm, getTopology().HTTPPort, inode)
+
+	var lastErr error
+	backoff := r.opts.Backoff
+	for attempt := 1; attempt <= r.opts.MaxAttempts; attempt++ {
+		p, retry, err := r.fetch(ctx, url)
+		if err == nil {
+			r.store(key, p)
+			return p, nil
+		}
+		if !retry {
+			return "", err
+		}
+		lastErr = err
+
+		if attempt == r.opts.MaxAttempts {
+			break
+		}
+		// exponential backoff with jitter
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		backoff *= 2
+		if backoff > r.opts.MaxBackoff {
+			backoff = r.opts.MaxBackoff
+		}
+	}
+	return "", fmt.Errorf("%s: giving up after %d attempts: %v", mgm, r.opts.MaxAttempts, lastErr)
+}
+
+// fetch does one fileinfo call, retry tells if the error is transient.
+func (r *pathResolver) fetch(ctx context.Context, url string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", true, fmt.Errorf("status %q", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", true, err
+	}
+	sb := strings.TrimSpace(string(body))
+	if sb == "" {
+		return "", false, errInodeNotFound
+	}
+	if len(sb) <= 8 {
+		return "", false, fmt.Errorf("unexpected fileinfo answer %q", sb)
+	}
+	return sb[8:], false, nil
+}
+
+func (r *pathResolver) limit(mgm string) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.limits[mgm]
+	if !ok {
+		l = make(chan struct{}, r.opts.Concurrency)
+		r.limits[mgm] = l
+	}
+	return l
+}
+
+func (r *pathResolver) cached(key string) (string, bool) {
+	r.mu.Lock()
+	e, ok := r.memory.get(key)
+	db := r.db
+	r.mu.Unlock()
+	if ok && time.Now().Before(e.Expires) {
+		return e.Path, true
+	}
+	if db == nil {
+		return "", false
+	}
+
+	e = &pathCacheEntry{}
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(pathCacheBucket))
+		if bucket == nil {
+			return errInodeNotFound
+		}
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return errInodeNotFound
+		}
+		return json.Unmarshal(data, e)
+	})
+	if err != nil || time.Now().After(e.Expires) {
+		return "", false
+	}
+
+	r.mu.Lock()
+	r.memory.put(key, e)
+	r.mu.Unlock()
+	return e.Path, true
+}
+
+func (r *pathResolver) store(key, p string) {
+	e := &pathCacheEntry{Path: p, Expires: time.Now().Add(r.opts.CacheTTL)}
+	r.mu.Lock()
+	r.memory.put(key, e)
+	db := r.db
+	r.mu.Unlock()
+	if db == nil {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	err = db.Batch(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(pathCacheBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), data)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error caching path of %s: %+v\n", key, err)
+	}
+}
+
+// pathLRU keeps the most recently used paths in memory up to size entries.
+// It is not safe for concurrent use, the resolver holds its lock.
+type pathLRU struct {
+	size    int
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type pathLRUItem struct {
+	key   string
+	entry *pathCacheEntry
+}
+
+func newPathLRU(size int) *pathLRU {
+	return &pathLRU{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *pathLRU) get(key string) (*pathCacheEntry, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*pathLRUItem).entry, true
+}
+
+func (c *pathLRU) put(key string, e *pathCacheEntry) {
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*pathLRUItem).entry = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&pathLRUItem{key: key, entry: e})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*pathLRUItem).key)
+	}
+}
+
+func (c *pathLRU) removeIf(match func(*pathCacheEntry) bool) {
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if item := el.Value.(*pathLRUItem); match(item.entry) {
+			c.order.Remove(el)
+			delete(c.entries, item.key)
+		}
+		el = next
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestPathLRU(t *testing.T) {
+	c := newPathLRU(2)
+	c.put("eoshome-g:1", &pathCacheEntry{Path: "/eos/user/g/gonzalhu/a"})
+	c.put("eoshome-g:2", &pathCacheEntry{Path: "/eos/user/g/gonzalhu/b"})
+	c.get("eoshome-g:1")
+	// the least recently used entry is evicted
+	c.put("eoshome-g:3", &pathCacheEntry{Path: "/eos/user/g/gonzalhu/c"})
+
+	tuples := map[string]bool{
+		"eoshome-g:1": true,
+		"eoshome-g:2": false,
+		"eoshome-g:3": true,
+	}
+	for key, expected := range tuples {
+		if _, got := c.get(key); got != expected {
+			t.Fatalf("%s got:%t expected:%t", key, got, expected)
+		}
+	}
+}
+
+func TestPathResolverInvalidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cernboxcop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := newPathResolver(&pathResolverOptions{CacheDB: path.Join(dir, "paths.db"), CacheTTL: time.Hour, CacheSize: 10})
+	defer r.close()
+
+	r.store("eosproject-c:1", "/eos/project/c/cernbox")
+	r.store("eosproject-c:2", "/eos/project/c/cernbox/docs")
+	r.store("eosproject-c:3", "/eos/project/c/cernbox-archive")
+	r.invalidate("/eos/project/c/cernbox")
+
+	tuples := map[string]bool{
+		"eosproject-c:1": false,
+		"eosproject-c:2": false,
+		"eosproject-c:3": true,
+	}
+	for key, expected := range tuples {
+		if _, got := r.cached(key); got != expected {
+			t.Fatalf("%s got:%t expected:%t", key, got, expected)
+		}
+	}
+
+	// the disk cache is invalidated too
+	r.memory = newPathLRU(10)
+	for key, expected := range tuples {
+		if _, got := r.cached(key); got != expected {
+			t.Fatalf("%s got:%t expected:%t on disk", key, got, expected)
+		}
+	}
+}
+
+func TestPathResolverFake(t *testing.T) {
+	viper.Set("storage_driver", "fake")
+	defer viper.Set("storage_driver", "")
+
+	ctx := context.Background()
+	s, err := getFakeStorage("", getTopology().mgmForInstance("eoshome-g"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateDir(ctx, "root", "/eos/user/g/gonzalhu/docs"); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newPathResolver(&pathResolverOptions{CacheTTL: time.Hour, CacheSize: 10})
+	defer r.close()
+
+	p, err := r.resolve(ctx, "eoshome-g", fmt.Sprintf("%d", fi.Inode), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "/eos/user/g/gonzalhu/docs" {
+		t.Fatalf("got:%s expected:/eos/user/g/gonzalhu/docs", p)
+	}
+	if _, err := r.resolve(ctx, "eoshome-g", "999999", true); err != errInodeNotFound {
+		t.Fatalf("got:%v expected:%v", err, errInodeNotFound)
+	}
+}
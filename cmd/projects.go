@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
+	"os"
 	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -21,9 +23,11 @@ func init() {
 	projectCmd.AddCommand(projectOrphanCmd)
 
 	projectListCmd.Flags().StringP("owner", "o", "", "filter by owner account")
+	projectListCmd.Flags().StringP("filter", "f", "", "filter expression, ex: 'owner=cboxsvc and (name=cern* or letter=c) and not orphan'")
 	projectOrphanCmd.Flags().BoolP("quiet", "q", false, "Only show projects name")
 	projectListCmd.Flags().BoolP("printpath", "", false, "print EOS path, it may take a while to run")
 	projectOrphanCmd.Flags().BoolP("printpath", "", false, "print EOS path, it may take a while to run")
+	projectOrphanCmd.Flags().StringP("direction", "", "db", "db: projects in the DB but not in EOS, eos: folders in EOS but not in the DB, all: both and projects not following <letter>/<name>")
 
 }
 
@@ -34,7 +38,7 @@ var projectCmd = &cobra.Command{
 
 var projectAddCmd = &cobra.Command{
 	Use:   "add <project-name> <svc-account>",
-	Short: "Adds a new project (in db only)",
+	Short: "Adds a new project (in db only), see project create",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 2 {
 			exit(cmd)
@@ -56,7 +60,7 @@ var projectAddCmd = &cobra.Command{
 
 var projectDeleteCmd = &cobra.Command{
 	Use:   "delete <project-name>",
-	Short: "Deletes a project (in db only)",
+	Short: "Deletes a project (in db only), see project retire",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
 			exit(cmd)
@@ -82,57 +86,180 @@ type FilterProject interface {
 type ByOrphan struct{}
 
 var cacheInitials map[string]bool = make(map[string]bool)
+var cacheInitialsErr map[string]error = make(map[string]error)
 var cacheProjectsName map[string]bool = make(map[string]bool)
 
 func (ByOrphan) In(pSpace *projectSpace) bool {
 	splitted := strings.SplitN(pSpace.rel, "/", 2) // splitted = [<initial letter> <project name>]
+	if len(splitted) != 2 {
+		// not following the convention, reported by orphan --direction all
+		return false
+	}
 	initialLetter := splitted[0]
 	projectName := splitted[1]
 
-	if !cacheInitials[initialLetter] {
-		// not in cache
-		// i should retrieve all project starting with the initial letter from EOS
-		mgm := fmt.Sprintf("root://eosproject-%s.cern.ch", initialLetter)
-		path := fmt.Sprintf("/eos/project/%s", initialLetter)
+	if err := cacheProjectLetter(initialLetter); err != nil {
+		er(err)
+	}
+	return !cacheProjectsName[projectName]
+}
 
-		files := getFilesInDirEOS(mgm, path)
+// cacheProjectLetter retrieves once the projects starting with the initial letter from EOS.
+func cacheProjectLetter(initialLetter string) error {
+	if cacheInitials[initialLetter] {
+		return cacheInitialsErr[initialLetter]
+	}
+	ns := getTopology().Project
+	mgm := ns.mgm(initialLetter)
+	path := ns.dir(initialLetter)
 
-		// put in cache
-		cacheInitials[initialLetter] = true
-		for _, file := range files {
-			cacheProjectsName[file] = true
-		}
+	files, err := getFilesInDirEOS(mgm, path)
+	cacheInitials[initialLetter] = true
+	if err != nil {
+		cacheInitialsErr[initialLetter] = fmt.Errorf("listing %s on %s: %v", path, mgm, err)
+		return cacheInitialsErr[initialLetter]
 	}
-	return !cacheProjectsName[projectName]
+	for _, file := range files {
+		cacheProjectsName[file] = true
+	}
+	return nil
 }
 
+const (
+	orphanInDB            = "db"
+	orphanInEOS           = "eos"
+	orphanAll             = "all"
+	orphanMissingInEOS    = "missing-in-eos"
+	orphanMissingInDB     = "missing-in-db"
+	orphanBadRelativePath = "bad-relative-path"
+)
+
 var projectOrphanCmd = &cobra.Command{
-	Use:   "orphan",
-	Short: "List only the projects which are in the DB but not in EOS",
+	Use:   "orphan [--direction db|eos|all]",
+	Short: "List the projects which are in the DB but not in EOS, or in EOS but not in the DB",
 	Run: func(cmd *cobra.Command, args []string) {
 
 		quiet, _ := cmd.Flags().GetBool("quiet")
 		printpath, _ := cmd.Flags().GetBool("printpath")
+		direction, _ := cmd.Flags().GetString("direction")
 
-		orphanSpaces := getProjects(ByOrphan{})
+		if direction == orphanInDB {
+			orphanSpaces := getProjects(ByOrphan{})
+
+			if quiet {
+				for _, orphan := range orphanSpaces {
+					fmt.Println(orphan.name)
+				}
+			} else {
+				printProjectSpaces(orphanSpaces, printpath)
+			}
+			return
+		}
+		if direction != orphanInEOS && direction != orphanAll {
+			exit(cmd)
+		}
+
+		cols := []string{"KIND", "NAME", "RELATIVE_PATH", "OWNER"}
+		rows := [][]string{}
+		projects := getProjects(All{})
+		// a letter that cannot be listed is reported and the others are still checked
+		failed := map[string]error{}
+		if direction == orphanAll {
+			for _, p := range projects {
+				if splitted := strings.SplitN(p.rel, "/", 2); len(splitted) == 2 {
+					if err := cacheProjectLetter(splitted[0]); err != nil {
+						failed[splitted[0]] = err
+						continue
+					}
+				}
+				if (ByOrphan{}).In(p) {
+					rows = append(rows, []string{orphanMissingInEOS, p.name, p.rel, p.owner})
+				}
+			}
+			for _, p := range projects {
+				if !followsProjectConvention(p) {
+					rows = append(rows, []string{orphanBadRelativePath, p.name, p.rel, p.owner})
+				}
+			}
+		}
+		missing, errs := getProjectFoldersNotInDB(projects)
+		for _, rel := range missing {
+			rows = append(rows, []string{orphanMissingInDB, path.Base(rel), rel, "-"})
+		}
+		for letter, err := range errs {
+			failed[letter] = err
+		}
 
 		if quiet {
-			for _, orphan := range orphanSpaces {
-				fmt.Println(orphan.name)
+			for _, row := range rows {
+				fmt.Println(row[1])
 			}
 		} else {
-			printProjectSpaces(orphanSpaces, printpath)
+			pretty(cols, rows)
+		}
+
+		if len(failed) > 0 {
+			letters := make([]string, 0, len(failed))
+			for letter := range failed {
+				letters = append(letters, letter)
+			}
+			sort.Strings(letters)
+			for _, letter := range letters {
+				fmt.Fprintf(os.Stderr, "Error: letter %s not checked: %v\n", letter, failed[letter])
+			}
+			os.Exit(1)
 		}
 	},
 }
 
-func getFilesInDirEOS(mgm, pathDir string) []string {
-	cmd := exec.Command("eos", mgm, "ls", pathDir)
-	out, err := cmd.Output()
+// followsProjectConvention tells if the relative path of the project is <letter>/<name>.
+func followsProjectConvention(p *projectSpace) bool {
+	return p.name != "" && p.rel == path.Join(string(p.name[0]), p.name)
+}
+
+// getProjectFoldersNotInDB returns the relative paths of the folders under
+// /eos/project/<letter>/ without a mapping row, and the letters that could not be listed.
+func getProjectFoldersNotInDB(projects []*projectSpace) ([]string, map[string]error) {
+	mapped := map[string]bool{}
+	for _, p := range projects {
+		mapped[p.rel] = true
+	}
+
+	ns := getTopology().Project
+	missing := []string{}
+	errs := map[string]error{}
+	for _, letter := range ns.letters() {
+		names, err := getFilesInDirEOS(ns.mgm(letter), ns.dir(letter))
+		if err != nil {
+			errs[letter] = fmt.Errorf("listing %s on %s: %v", ns.dir(letter), ns.mgm(letter), err)
+			continue
+		}
+		for _, name := range names {
+			// archives and other dot-folders are not projects
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			rel := path.Join(letter, name)
+			if !mapped[rel] && !mapped[name] {
+				missing = append(missing, rel)
+			}
+		}
+	}
+	return missing, errs
+}
+
+func getFilesInDirEOS(mgm, pathDir string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	mds, err := getEOS(mgm).List(ctx, "root", pathDir)
 	if err != nil {
-		er(err)
+		return nil, err
+	}
+	files := make([]string, 0, len(mds))
+	for _, md := range mds {
+		files = append(files, path.Base(md.File))
 	}
-	return strings.Split(string(out), "\n")
+	return files, nil
 }
 
 var projectUpdateSvcAccount = &cobra.Command{
@@ -169,13 +296,16 @@ var projectListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		owner, _ := cmd.Flags().GetString("owner")
 		printpath, _ := cmd.Flags().GetBool("printpath")
-		
-		var projects []*projectSpace
-		if owner == "" {
-			projects = getProjects(All{})
-		} else {
-			projects = getProjects(ByOwner(owner))	
+		expr, _ := cmd.Flags().GetString("filter")
+
+		filter, err := parseFilter(expr)
+		if err != nil {
+			er(err)
+		}
+		if owner != "" {
+			filter = And{ByOwner(owner), filter}
 		}
+		projects := getProjects(filter)
 		printProjectSpaces(projects, printpath)
 	},
 }
@@ -242,7 +372,11 @@ func getProjects(filter FilterProject) (projects []*projectSpace) {
 	db := getDB()
 
 	query := "SELECT project_name, eos_relative_path, project_owner FROM cernbox_project_mapping"
-	rows, err := db.Query(query)
+	where, args, _ := whereOf(filter)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		er(err)
 	}
@@ -310,40 +444,17 @@ func (All) In(*projectSpace) bool {
 }
 
 func getProjectOwner(nameOrPath string) (string, error) {
-	relpath := getProjectRelPath(nameOrPath)
-	projects := getProjects(All{})
-	for i := range projects {
-		if projects[i].rel == relpath {
-			return projects[i].owner, nil
-		}
-
-		// give it a try without letter prefix, historically
-		// there will be projects like "cernbox" or "ski club" under /eos/project
-		base := path.Base(nameOrPath)
-		if projects[i].rel == base {
-			return projects[i].owner, nil
-		}
+	project, err := getProject(nameOrPath)
+	if err != nil {
+		return "", err
 	}
-
-	return "", errors.New("error finding project owner for relative path: " + relpath)
+	return project.owner, nil
 }
 
+// getProject returns the project of a name or of any path inside the project,
+// see findProject.
 func getProject(nameOrPath string) (*projectSpace, error) {
-	relpath := getProjectRelPath(nameOrPath)
-	projects := getProjects(All{})
-	for i := range projects {
-		if projects[i].rel == relpath {
-			return projects[i], nil
-		}
-
-		// give it a try without letter prefix, historically
-		// there will be projects like "cernbox" or "ski club" under /eos/project
-		base := path.Base(nameOrPath)
-		if projects[i].rel == base {
-			return projects[i], nil
-		}
-	}
-	return nil, errors.New("not found")
+	return findProject(nameOrPath, getProjects(All{}), getTopology().Project.Prefix)
 }
 
 // name = cernbox
@@ -360,7 +471,7 @@ type projectSpace struct{ name, rel, owner string }
 
 func (p *projectSpace) GetPath() string {
 
-	fullPath := path.Join("/eos/project", p.rel)
+	fullPath := path.Join(getTopology().Project.Prefix, p.rel)
 	client := getEOSForProject(p.name)
 	ctx := context.Background()
 	_, err := client.GetFileInfoByPath(ctx, "root", fullPath)
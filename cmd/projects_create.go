@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	projectCmd.AddCommand(projectCreateCmd)
+
+	projectCreateCmd.Flags().StringP("owner", "o", "", "service account owning the project")
+	projectCreateCmd.Flags().StringP("quota", "", "", "quota of the project, like 1TB")
+	projectCreateCmd.Flags().Uint64P("files", "", 1000000, "maximum number of files of the project")
+	projectCreateCmd.Flags().BoolP("dry-run", "", false, "only show the steps that would be done")
+}
+
+// new project names, historical ones may contain spaces and capitals
+var projectNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// projectACLs returns the sys.acl entries of a project following the
+// cernbox-project-<name>-{admins,writers,readers} egroup convention.
+func projectACLs(name string) []*acl.Entry {
+	return []*acl.Entry{
+		{Type: aclTypeEgroup, Qualifier: fmt.Sprintf("cernbox-project-%s-admins", name), Permissions: aclPermReadWrite},
+		{Type: aclTypeEgroup, Qualifier: fmt.Sprintf("cernbox-project-%s-writers", name), Permissions: aclPermReadWrite},
+		{Type: aclTypeEgroup, Qualifier: fmt.Sprintf("cernbox-project-%s-readers", name), Permissions: aclPermReadOnly},
+	}
+}
+
+// getProjectACLs returns the admins, writers and readers entries of the sys.acl
+// of the project folder. A renamed project keeps the e-groups of its former
+// name, so they are read from EOS instead of derived from the name.
+func getProjectACLs(p *projectSpace) ([]*acl.Entry, error) {
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	fi, err := getEOSForProject(p.name).GetFileInfoByPath(ctx, "root", path.Join(getTopology().Project.Prefix, p.rel))
+	if err != nil {
+		return nil, err
+	}
+	return projectACLsOf(fi.SysACL, p.name), nil
+}
+
+// getProjectAdminEgroup returns the admins e-group set on the project folder.
+func getProjectAdminEgroup(p *projectSpace) (string, error) {
+	entries, err := getProjectACLs(p)
+	if err != nil {
+		return "", err
+	}
+	return entries[0].Qualifier, nil
+}
+
+// projectACLsOf returns the entries of projectACLs with the e-groups found in
+// the sys.acl, the roles missing from it keep the ones of the convention.
+func projectACLsOf(sysACL, name string) []*acl.Entry {
+	entries := projectACLs(name)
+	acls, err := acl.Parse(sysACL, acl.ShortTextForm)
+	if err != nil {
+		return entries
+	}
+	for _, e := range entries {
+		role := e.Qualifier[strings.LastIndex(e.Qualifier, "-"):]
+		for _, a := range acls.Entries {
+			if a.Type == aclTypeEgroup && strings.HasPrefix(a.Qualifier, "cernbox-project-") && strings.HasSuffix(a.Qualifier, role) {
+				e.Qualifier = a.Qualifier
+				break
+			}
+		}
+	}
+	return entries
+}
+
+var projectCreateCmd = &cobra.Command{
+	Use:   "create <project-name> --owner <svc-account> --quota <size>\nExample: cernboxcop project create cernbox --owner cboxsvc --quota 1TB",
+	Short: "Creates a project space in EOS and in the db",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		quota, _ := cmd.Flags().GetString("quota")
+		files, _ := cmd.Flags().GetUint64("files")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		name := strings.TrimSpace(args[0])
+		owner = strings.TrimSpace(owner)
+		if owner == "" || quota == "" {
+			exit(cmd)
+		}
+		if !projectNameRegexp.MatchString(name) {
+			er(fmt.Sprintf("invalid project name %q, only lowercase letters, digits, - and _ are allowed", name))
+		}
+		bytes, err := humanize.ParseBytes(quota)
+		if err != nil {
+			er(err)
+		}
+
+		uid, gid := validateNewProject(name, owner)
+
+		steps := projectCreateSteps(name, owner, uid, gid, bytes, files)
+		if dryRun {
+			for i, s := range steps {
+				fmt.Printf("%d. %s\n", i+1, s.name)
+			}
+			return
+		}
+		checkAuditLog()
+
+		if err := runSteps(steps); err != nil {
+			er(err)
+		}
+
+		auditLog("project-create", map[string]string{
+			"name":  name,
+			"owner": owner,
+			"path":  getTopology().Project.path(name),
+			"quota": fmt.Sprintf("%d", bytes),
+			"files": fmt.Sprintf("%d", files),
+		})
+		fmt.Printf("project %s created in %s\n", name, getTopology().Project.path(name))
+	},
+}
+
+// validateNewProject exits if the project cannot be created,
+// it returns the uid and gid of the service account in LDAP.
+func validateNewProject(name, owner string) (uint64, uint64) {
+	for _, p := range getProjects(All{}) {
+		if p.name == name || p.rel == getProjectRelPath(name) {
+			er(fmt.Sprintf("project %q already exists", name))
+		}
+		// the quota is set per service account on the project quota node
+		if p.owner == owner {
+			er(fmt.Sprintf("service account %q already owns project %q", owner, p.name))
+		}
+	}
+
+	lc := getLDAP()
+	defer lc.Close()
+	ui := getUser(lc, owner)
+	if ui.Account == "" {
+		er(fmt.Sprintf("service account %q does not exist", owner))
+	}
+	uid, gid, err := ui.unixIDs()
+	if err != nil {
+		er(err)
+	}
+	for _, e := range projectACLs(name) {
+		if _, exists := getEgroupMembers(lc, e.Qualifier); !exists {
+			er(fmt.Sprintf("egroup %q does not exist, please create it first", e.Qualifier))
+		}
+	}
+
+	p := getTopology().Project.path(name)
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	if _, err := getEOS(getTopology().mgmForProject(name)).GetFileInfoByPath(ctx, "root", p); err == nil {
+		er(fmt.Sprintf("%s already exists in EOS", p))
+	}
+	return uid, gid
+}
+
+func projectCreateSteps(name, owner string, uid, gid uint64, bytes, files uint64) []*provisionStep {
+	mgm := getTopology().mgmForProject(name)
+	client := getEOS(mgm)
+	p := getTopology().Project.path(name)
+	node := getTopology().Project.Prefix + "/"
+
+	steps := []*provisionStep{
+		{
+			name: fmt.Sprintf("create %s on %s", p, mgm),
+			do:   withEOSTimeout(func(ctx context.Context) error { return client.CreateDir(ctx, "root", p) }),
+			undo: withEOSTimeout(func(ctx context.Context) error { return client.Remove(ctx, "root", p) }),
+		},
+		{
+			// the ids of LDAP, the admin host may not know the service account
+			name: fmt.Sprintf("chown %s to %s (%d:%d)", p, owner, uid, gid),
+			do:   withEOSTimeout(func(ctx context.Context) error { return client.ChownIDs(ctx, uid, gid, p) }),
+		},
+		{
+			name: fmt.Sprintf("set quota of %s on %s to %s and %d files", owner, node, humanize.Bytes(bytes), files),
+			do:   withEOSTimeout(func(ctx context.Context) error { return client.SetQuota(ctx, owner, node, bytes, files) }),
+			undo: withEOSTimeout(func(ctx context.Context) error { return client.RmQuota(ctx, owner, node) }),
+		},
+	}
+	for _, e := range projectACLs(name) {
+		e := e
+		steps = append(steps, &provisionStep{
+			name: fmt.Sprintf("add ACL %s to %s", aclString(e), p),
+			do:   withEOSTimeout(func(ctx context.Context) error { return client.AddACL(ctx, "root", p, e) }),
+		})
+	}
+	steps = append(steps, &provisionStep{
+		name: fmt.Sprintf("add project %s owned by %s to the db", name, owner),
+		do:   func() error { return addProject(name, owner) },
+		undo: func() error { return deleteProject(&projectSpace{name: name}) },
+	})
+	return steps
+}
+
+// provisionStep is one step of a provisioning, undo reverts it
+// when a later step fails and is nil if nothing has to be reverted.
+type provisionStep struct {
+	name string
+	do   func() error
+	undo func() error
+}
+
+// withEOSTimeout returns a step running the EOS call with a timeout.
+func withEOSTimeout(f func(ctx context.Context) error) func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+		defer cancel()
+		return f(ctx)
+	}
+}
+
+// runSteps runs the steps in order, when one fails the completed steps are undone in reverse order.
+func runSteps(steps []*provisionStep) error {
+	for i, s := range steps {
+		fmt.Fprintf(os.Stderr, "%s\n", s.name)
+		err := s.do()
+		if err == nil {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %+v, rolling back\n", err)
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].undo == nil {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "undo: %s\n", steps[j].name)
+			if uerr := steps[j].undo(); uerr != nil {
+				fmt.Fprintf(os.Stderr, "error undoing %q, please fix it manually: %+v\n", steps[j].name, uerr)
+			}
+		}
+		return fmt.Errorf("%s: %v", s.name, err)
+	}
+	return nil
+}
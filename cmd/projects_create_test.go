@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestProjectACLsOf(t *testing.T) {
+	tuples := map[string]string{
+		// a renamed project keeps the e-groups of its former name
+		"egroup:cernbox-project-cbox-admins:rwx+d,egroup:cernbox-project-cbox-writers:rwx+d,egroup:cernbox-project-cbox-readers:rx": "cernbox-project-cbox-admins,cernbox-project-cbox-writers,cernbox-project-cbox-readers",
+		"u:1234:rx,egroup:cernbox-project-cbox-readers:rx":                                                                          "cernbox-project-cernbox-admins,cernbox-project-cernbox-writers,cernbox-project-cbox-readers",
+		"": "cernbox-project-cernbox-admins,cernbox-project-cernbox-writers,cernbox-project-cernbox-readers",
+	}
+
+	for sysACL, expected := range tuples {
+		egroups := []string{}
+		for _, e := range projectACLsOf(sysACL, "cernbox") {
+			egroups = append(egroups, e.Qualifier)
+		}
+		if got := strings.Join(egroups, ","); got != expected {
+			t.Fatalf("got:%s expected:%s", got, expected)
+		}
+	}
+}
+
+func TestProjectCreateSteps(t *testing.T) {
+	viper.Set("storage_driver", "fake")
+	defer viper.Set("storage_driver", "")
+	// the steps use the context of the commands
+	log = newLogger("")
+
+	steps := projectCreateSteps("lhcb-docs", "lhcbsvc", 4321, 2766, 1000, 10)
+	// all but the db step
+	if err := runSteps(steps[:len(steps)-1]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s, err := getFakeStorage("", getTopology().mgmForProject("lhcb-docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := s.GetFileInfoByPath(ctx, "root", "/eos/project/l/lhcb-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.UID != 4321 || fi.GID != 2766 {
+		t.Fatalf("got:%d:%d expected:4321:2766", fi.UID, fi.GID)
+	}
+	expected := "egroup:cernbox-project-lhcb-docs-admins:rwx+d,egroup:cernbox-project-lhcb-docs-writers:rwx+d,egroup:cernbox-project-lhcb-docs-readers:rx"
+	if fi.SysACL != expected {
+		t.Fatalf("got:%s expected:%s", fi.SysACL, expected)
+	}
+	q, err := s.GetQuota(ctx, "lhcbsvc", "/eos/project/l/lhcb-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.AvailableBytes != 1000 || q.AvailableInodes != 10 {
+		t.Fatalf("got:%d:%d expected:1000:10", q.AvailableBytes, q.AvailableInodes)
+	}
+}
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+)
+
+// Project filters can be combined on the command line with --filter, ex:
+//
+//	owner=cboxsvc and (name=cern* or letter=c) and not orphan
+//
+// Terms:
+//
+//	all, orphan
+//	owner=<account>
+//	name=<glob>           name=cern*
+//	letter=<letter>       letter=c
+//	instance=<instance>   instance=eosproject-c
+//	usage>N, usage<N      used bytes over the quota in %
+//	created>D, created<D  creation day of the EOS folder (YYYY-MM-DD)
+//
+// The filters implementing SQLFilter are pushed down into the WHERE clause.
+
+// SQLFilter is a FilterProject that can be pushed down into the WHERE clause.
+// Where returns the condition with its arguments and tells if it selects
+// exactly the same projects as In, otherwise it only narrows the scan.
+type SQLFilter interface {
+	FilterProject
+	Where() (string, []interface{}, bool)
+}
+
+// whereOf returns the pushed down condition of any filter, empty if it has none.
+func whereOf(f FilterProject) (string, []interface{}, bool) {
+	if s, ok := f.(SQLFilter); ok {
+		return s.Where()
+	}
+	return "", nil, false
+}
+
+func (All) Where() (string, []interface{}, bool) {
+	return "", nil, true
+}
+
+// The MySQL collation compares case-insensitively, so the owner, name and
+// letter conditions only narrow the scan and In decides.
+func (owner ByOwner) Where() (string, []interface{}, bool) {
+	return "project_owner=?", []interface{}{string(owner)}, false
+}
+
+type And []FilterProject
+
+func (f And) In(pSpace *projectSpace) bool {
+	for _, c := range f {
+		if !c.In(pSpace) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f And) Where() (string, []interface{}, bool) {
+	clauses := []string{}
+	args := []interface{}{}
+	exact := true
+	for _, c := range f {
+		where, whereArgs, ok := whereOf(c)
+		exact = exact && ok
+		if where != "" {
+			clauses = append(clauses, "("+where+")")
+			args = append(args, whereArgs...)
+		}
+	}
+	return strings.Join(clauses, " AND "), args, exact
+}
+
+type Or []FilterProject
+
+func (f Or) In(pSpace *projectSpace) bool {
+	for _, c := range f {
+		if c.In(pSpace) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Or) Where() (string, []interface{}, bool) {
+	clauses := []string{}
+	args := []interface{}{}
+	exact := true
+	for _, c := range f {
+		where, whereArgs, ok := whereOf(c)
+		if where == "" {
+			// one branch matching anything in SQL makes the whole OR match anything
+			return "", nil, false
+		}
+		exact = exact && ok
+		clauses = append(clauses, "("+where+")")
+		args = append(args, whereArgs...)
+	}
+	return strings.Join(clauses, " OR "), args, exact
+}
+
+type Not struct{ FilterProject }
+
+func (f Not) In(pSpace *projectSpace) bool {
+	return !f.FilterProject.In(pSpace)
+}
+
+func (f Not) Where() (string, []interface{}, bool) {
+	where, args, exact := whereOf(f.FilterProject)
+	if where == "" || !exact {
+		return "", nil, false
+	}
+	return "NOT (" + where + ")", args, true
+}
+
+// ByName matches the project name with a shell glob.
+type ByName string
+
+func (glob ByName) In(pSpace *projectSpace) bool {
+	ok, _ := path.Match(string(glob), pSpace.name)
+	return ok
+}
+
+func (glob ByName) Where() (string, []interface{}, bool) {
+	if strings.ContainsAny(string(glob), `[]\`) {
+		return "", nil, false
+	}
+	like := strings.NewReplacer(`%`, `\%`, `_`, `\_`, "*", "%", "?", "_").Replace(string(glob))
+	return "project_name LIKE ?", []interface{}{like}, false
+}
+
+// ByLetter matches the projects stored under /eos/project/<letter>.
+type ByLetter string
+
+func (letter ByLetter) In(pSpace *projectSpace) bool {
+	return strings.HasPrefix(pSpace.rel, string(letter)+"/")
+}
+
+func (letter ByLetter) Where() (string, []interface{}, bool) {
+	like := strings.NewReplacer(`%`, `\%`, `_`, `\_`).Replace(string(letter))
+	return "eos_relative_path LIKE ?", []interface{}{like + "/%"}, false
+}
+
+// ByInstance matches the projects stored in the EOS instance.
+type ByInstance string
+
+func (instance ByInstance) In(pSpace *projectSpace) bool {
+	t := getTopology()
+	return t.instanceForMGM(t.mgmForProject(pSpace.name)) == t.canonicalInstance(string(instance))
+}
+
+// ByQuotaUsage matches the projects using more (or less) than the percentage of their quota.
+type ByQuotaUsage struct {
+	Percent float64
+	Less    bool
+}
+
+// project quotas by MGM and account, shared by the usage filters
+var cacheProjectQuotas = map[string]map[string]*eosclient.QuotaInfo{}
+
+func (f ByQuotaUsage) In(pSpace *projectSpace) bool {
+	mgm := getTopology().mgmForProject(pSpace.name)
+	quotas, ok := cacheProjectQuotas[mgm]
+	if !ok {
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+		defer cancel()
+		var err error
+		quotas, err = getEOS(mgm).DumpQuotas(ctx, getTopology().Project.Prefix+"/")
+		if err != nil {
+			er(err)
+		}
+		cacheProjectQuotas[mgm] = quotas
+	}
+
+	q, ok := quotas[pSpace.owner]
+	if !ok || q.AvailableBytes == 0 {
+		return false
+	}
+	usage := float64(q.UsedBytes) * 100 / float64(q.AvailableBytes)
+	if f.Less {
+		return usage < f.Percent
+	}
+	return usage > f.Percent
+}
+
+// ByCreation matches the projects whose EOS folder was created after (or before) the time.
+type ByCreation struct {
+	Time   time.Time
+	Before bool
+}
+
+func (f ByCreation) In(pSpace *projectSpace) bool {
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	fi, err := getEOSForProject(pSpace.name).GetFileInfoByPath(ctx, "root", path.Join(getTopology().Project.Prefix, pSpace.rel))
+	if err != nil {
+		return false
+	}
+	ctime, err := strconv.ParseInt(strings.Split(fi.Attrs["ctime"], ".")[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	created := time.Unix(ctime, 0)
+	if f.Before {
+		return created.Before(f.Time)
+	}
+	return created.After(f.Time)
+}
+
+// parseFilter parses a filter expression, see the terms above.
+func parseFilter(expr string) (FilterProject, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return All{}, nil
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+// tokenizeFilter splits the expression in parentheses and words,
+// quotes keep spaces and parentheses in values: name="ski club".
+func tokenizeFilter(expr string) ([]string, error) {
+	tokens := []string{}
+	cur := strings.Builder{}
+	var quote rune
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in filter")
+	}
+	flush()
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) or() (FilterProject, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	filters := Or{f}
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		f, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return filters, nil
+}
+
+func (p *filterParser) and() (FilterProject, error) {
+	f, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	filters := And{f}
+	for strings.EqualFold(p.peek(), "and") {
+		p.pos++
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return filters, nil
+}
+
+func (p *filterParser) unary() (FilterProject, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end of filter")
+	case strings.EqualFold(tok, "not"):
+		p.pos++
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{f}, nil
+	case tok == "(":
+		p.pos++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing ) in filter")
+		}
+		p.pos++
+		return f, nil
+	}
+	p.pos++
+	return parseFilterTerm(tok)
+}
+
+func parseFilterTerm(term string) (FilterProject, error) {
+	switch term {
+	case "all":
+		return All{}, nil
+	case "orphan":
+		return ByOrphan{}, nil
+	}
+
+	i := strings.IndexAny(term, "=<>")
+	if i <= 0 || i == len(term)-1 {
+		return nil, fmt.Errorf("invalid filter term %q", term)
+	}
+	key, op, value := term[:i], term[i], term[i+1:]
+
+	switch {
+	case key == "owner" && op == '=':
+		return ByOwner(value), nil
+	case key == "name" && op == '=':
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid name glob %q", value)
+		}
+		return ByName(value), nil
+	case key == "letter" && op == '=':
+		return ByLetter(value), nil
+	case key == "instance" && op == '=':
+		return ByInstance(value), nil
+	case key == "usage" && op != '=':
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage %q", value)
+		}
+		return ByQuotaUsage{Percent: percent, Less: op == '<'}, nil
+	case key == "created" && op != '=':
+		t, err := time.ParseInLocation(dayLayout, value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation day %q", value)
+		}
+		return ByCreation{Time: t, Before: op == '<'}, nil
+	}
+	return nil, fmt.Errorf("invalid filter term %q", term)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	projects := []*projectSpace{
+		{name: "cernbox", rel: "c/cernbox", owner: "cboxsvc"},
+		{name: "cern_doc", rel: "c/cern_doc", owner: "docsvc"},
+		{name: "ski club", rel: "ski club", owner: "skisvc"},
+		{name: "atlas", rel: "a/atlas", owner: "cboxsvc"},
+	}
+
+	type result struct{ where, args, names string }
+	tuples := map[string]result{
+		"":                              {"", "[]", "cernbox,cern_doc,ski club,atlas"},
+		"owner=cboxsvc":                 {"project_owner=?", "[cboxsvc]", "cernbox,atlas"},
+		"name=cern*":                    {"project_name LIKE ?", "[cern%]", "cernbox,cern_doc"},
+		"name=cern_*":                   {"project_name LIKE ?", `[cern\_%]`, "cern_doc"},
+		"name='ski club'":               {"project_name LIKE ?", "[ski club]", "ski club"},
+		"letter=c and not owner=docsvc": {"(eos_relative_path LIKE ?)", "[c/%]", "cernbox"},
+		"not name=Cern*":                {"", "[]", "cernbox,cern_doc,ski club,atlas"},
+		"owner=docsvc or (letter=a)":    {"(project_owner=?) OR (eos_relative_path LIKE ?)", "[docsvc a/%]", "cern_doc,atlas"},
+		"not (letter=c or letter=a)":    {"", "[]", "ski club"},
+		"not letter=C":                  {"", "[]", "cernbox,cern_doc,ski club,atlas"},
+		"NOT name=[a-c]*":               {"", "[]", "ski club"},
+	}
+
+	for expr, expected := range tuples {
+		f, err := parseFilter(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		where, args, _ := whereOf(f)
+		if where != expected.where {
+			t.Fatalf("got:%s expected:%s", where, expected.where)
+		}
+		if got := fmt.Sprintf("%v", args); got != expected.args {
+			t.Fatalf("got:%s expected:%s", got, expected.args)
+		}
+		names := []string{}
+		for _, p := range projects {
+			if f.In(p) {
+				names = append(names, p.name)
+			}
+		}
+		if got := strings.Join(names, ","); got != expected.names {
+			t.Fatalf("got:%s expected:%s", got, expected.names)
+		}
+	}
+
+	for _, expr := range []string{"owner", "(letter=c", "letter=c and", "usage=10", "created>yesterday", "name='ski"} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
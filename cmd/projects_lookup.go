@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maximum number of suggestions when a project is not found
+const projectSuggestions = 5
+
+// findProject returns the project matching, case-insensitively:
+//
+//	cernbox
+//	c/cernbox/docs
+//	/eos/project/c/cernbox/docs/notes.txt
+//	/eos/project/cernbox (historical projects without letter)
+//
+// When nothing matches, the error suggests the closest project names.
+func findProject(nameOrPath string, projects []*projectSpace, prefix string) (*projectSpace, error) {
+	query := strings.Trim(strings.TrimSpace(nameOrPath), "/")
+	if query == "" {
+		return nil, fmt.Errorf("empty project name")
+	}
+
+	p := path.Clean("/" + strings.TrimSpace(nameOrPath))
+	if rel := strings.TrimPrefix(p, path.Clean(prefix)+"/"); rel != p {
+		query = rel
+	}
+
+	// walk up the path to the project root, the deepest match wins
+	for dir := query; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		for _, project := range projects {
+			if strings.EqualFold(project.rel, dir) {
+				return project, nil
+			}
+		}
+	}
+
+	name := projectNameOf(query)
+	for _, project := range projects {
+		if strings.EqualFold(project.name, name) || strings.EqualFold(project.rel, getProjectRelPath(name)) {
+			return project, nil
+		}
+	}
+
+	suggestions := closestProjects(name, projects)
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("project %q not found", name)
+	}
+	return nil, fmt.Errorf("project %q not found, did you mean: %s?", name, strings.Join(suggestions, ", "))
+}
+
+// projectNameOf returns the project name of a relative path,
+// c/cernbox/docs => cernbox, cernbox/docs => cernbox
+func projectNameOf(rel string) string {
+	parts := strings.Split(rel, "/")
+	if len(parts) > 1 && len(parts[0]) == 1 {
+		return parts[1]
+	}
+	return parts[0]
+}
+
+// closestProjects returns the names of the projects within a small edit distance of the name.
+func closestProjects(name string, projects []*projectSpace) []string {
+	name = strings.ToLower(name)
+	max := len(name) / 3
+	if max < 2 {
+		max = 2
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	candidates := []candidate{}
+	for _, project := range projects {
+		d := levenshtein(name, strings.ToLower(project.name))
+		if d <= max {
+			candidates = append(candidates, candidate{project.name, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	names := []string{}
+	for i := 0; i < len(candidates) && i < projectSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
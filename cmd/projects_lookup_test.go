@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestFindProject(t *testing.T) {
+	projects := []*projectSpace{
+		{name: "cernbox", rel: "c/cernbox", owner: "cboxsvc"},
+		{name: "cern-docs", rel: "c/cern-docs", owner: "docsvc"},
+		{name: "ski club", rel: "ski club", owner: "skisvc"},
+	}
+
+	tuples := map[string]string{
+		"cernbox":                               "cernbox",
+		"CERNBox":                               "cernbox",
+		"c/cernbox/":                            "cernbox",
+		"/eos/project/c/cernbox":                "cernbox",
+		"/eos/project/c/CERNBOX/docs/notes.txt": "cernbox",
+		"/eos/project/c/cern-docs/a/b":          "cern-docs",
+		"/eos/project/ski club/photos":          "ski club",
+		"Ski Club":                              "ski club",
+	}
+
+	for nameOrPath, expected := range tuples {
+		p, err := findProject(nameOrPath, projects, "/eos/project")
+		if err != nil {
+			t.Fatalf("%s: %v", nameOrPath, err)
+		}
+		if p.name != expected {
+			t.Fatalf("got:%s expected:%s", p.name, expected)
+		}
+	}
+
+	notFound := map[string]string{
+		"cernbx":                  `project "cernbx" not found, did you mean: cernbox?`,
+		"/eos/project/c/cerndocs": `project "cerndocs" not found, did you mean: cern-docs?`,
+		"atlas":                   `project "atlas" not found`,
+	}
+	for nameOrPath, expected := range notFound {
+		_, err := findProject(nameOrPath, projects, "/eos/project")
+		if err == nil || err.Error() != expected {
+			t.Fatalf("got:%v expected:%s", err, expected)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tuples := map[[2]string]int{
+		{"", ""}:               0,
+		{"cernbox", "cernbox"}: 0,
+		{"cernbx", "cernbox"}:  1,
+		{"kitten", "sitting"}:  3,
+		{"", "abc"}:            3,
+	}
+
+	for words, expected := range tuples {
+		if got := levenshtein(words[0], words[1]); got != expected {
+			t.Fatalf("got:%d expected:%d", got, expected)
+		}
+	}
+}
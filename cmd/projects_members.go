@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	projectCmd.AddCommand(projectMembersCmd)
+}
+
+var projectMembersCmd = &cobra.Command{
+	Use:   "members <project name or path>\nExample: cernboxcop project members cernbox --output json",
+	Short: "Lists who can access a project through its admins, writers and readers e-groups",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		project, err := getProject(strings.TrimSpace(args[0]))
+		if err != nil {
+			er(err)
+		}
+
+		entries, err := getProjectACLs(project)
+		if err != nil {
+			er(err)
+		}
+
+		lc := getLDAP()
+		defer lc.Close()
+
+		roles := map[string][]string{}
+		via := map[string][]string{}
+		for _, e := range entries {
+			role := e.Qualifier[strings.LastIndex(e.Qualifier, "-")+1:]
+			members, exists := expandEgroupMembers(lc, e.Qualifier)
+			if !exists {
+				fmt.Fprintf(os.Stderr, "e-group %s does not exist\n", e.Qualifier)
+				continue
+			}
+			for account, egroup := range members {
+				roles[account] = append(roles[account], role)
+				via[account] = append(via[account], egroup)
+			}
+		}
+
+		accounts := make([]string, 0, len(roles))
+		for account := range roles {
+			accounts = append(accounts, account)
+		}
+		sort.Strings(accounts)
+
+		cols := []string{"ACCOUNT", "NAME", "TYPE", "DEPARTMENT", "ROLES", "VIA"}
+		rows := [][]string{}
+		for _, account := range accounts {
+			ui := getUser(lc, account)
+			rows = append(rows, []string{account, ui.Name, ui.AccountType, ui.Department, strings.Join(roles[account], ","), strings.Join(via[account], ",")})
+		}
+		pretty(cols, rows)
+	},
+}
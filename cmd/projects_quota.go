@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	projectCmd.AddCommand(projectQuotaCmd)
+	projectQuotaCmd.AddCommand(projectQuotaGetCmd)
+	projectQuotaCmd.AddCommand(projectQuotaSetCmd)
+
+	projectQuotaSetCmd.Flags().StringP("bytes", "", "", "new quota, like 2TB")
+	projectQuotaSetCmd.Flags().Uint64P("files", "", 0, "new maximum number of files (default keeps the current one, or project_default_files without quota)")
+	projectQuotaSetCmd.Flags().BoolP("force", "", false, "allow increases above project_quota_approval_threshold")
+	projectQuotaSetCmd.Flags().StringP("ticket", "", "", "ticket approving the increase, required with --force")
+	projectQuotaSetCmd.Flags().BoolP("yes", "y", false, "sets the quota without confirmation")
+}
+
+// getProjectQuotaApprovalThreshold returns the quota in bytes above which
+// increases need an approval ticket, project_quota_approval_threshold: 10TB
+func getProjectQuotaApprovalThreshold() uint64 {
+	threshold := viper.GetString("project_quota_approval_threshold")
+	if threshold == "" {
+		threshold = "10TB"
+	}
+	bytes, err := humanize.ParseBytes(threshold)
+	if err != nil {
+		er(fmt.Sprintf("invalid project_quota_approval_threshold: %v", err))
+	}
+	return bytes
+}
+
+// getProjectDefaultFiles returns the maximum number of files of a project
+// without quota, project_default_files: 1000000 like project create.
+func getProjectDefaultFiles() uint64 {
+	files := uint64(viper.GetInt64("project_default_files"))
+	if files == 0 {
+		files = 1000000
+	}
+	return files
+}
+
+// newProjectFiles returns the maximum number of files to set, the current one
+// when not given or the default when the project has no quota yet.
+func newProjectFiles(files uint64, q *eosclient.QuotaInfo) uint64 {
+	switch {
+	case files != 0:
+		return files
+	case q.AvailableInodes > 0:
+		return uint64(q.AvailableInodes)
+	default:
+		return getProjectDefaultFiles()
+	}
+}
+
+var projectQuotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Project quotas",
+}
+
+var projectQuotaGetCmd = &cobra.Command{
+	Use:   "get <project-name>",
+	Short: "Shows the quota of a project",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		project, err := getProject(strings.TrimSpace(args[0]))
+		if err != nil {
+			er(err)
+		}
+		mgm := getTopology().mgmForProject(project.name)
+		q := getEOSProjectQuota(mgm, project.owner)
+
+		usage := "-"
+		if q.AvailableBytes > 0 {
+			usage = fmt.Sprintf("%.1f%%", float64(q.UsedBytes)*100/float64(q.AvailableBytes))
+		}
+		cols := []string{"NAME", "OWNER", "MGM", "USED", "MAX", "USAGE", "USED_FILES", "MAX_FILES"}
+		rows := [][]string{{project.name, project.owner, mgm, humanQuota(q.UsedBytes), humanQuota(q.AvailableBytes), usage, fmt.Sprintf("%d", q.UsedInodes), fmt.Sprintf("%d", q.AvailableInodes)}}
+		pretty(cols, rows)
+	},
+}
+
+var projectQuotaSetCmd = &cobra.Command{
+	Use:   "set <project-name> --bytes <size> [--files <n>] [--force --ticket <ticket>]",
+	Short: "Sets the quota of a project, increases above the approval threshold need --force and --ticket",
+	Run: func(cmd *cobra.Command, args []string) {
+		size, _ := cmd.Flags().GetString("bytes")
+		files, _ := cmd.Flags().GetUint64("files")
+		force, _ := cmd.Flags().GetBool("force")
+		ticket, _ := cmd.Flags().GetString("ticket")
+		yes, _ := cmd.Flags().GetBool("yes")
+		ticket = strings.TrimSpace(ticket)
+		if len(args) != 1 || size == "" {
+			exit(cmd)
+		}
+
+		bytes, err := humanize.ParseBytes(size)
+		if err != nil {
+			er(err)
+		}
+
+		project, err := getProject(strings.TrimSpace(args[0]))
+		if err != nil {
+			er(err)
+		}
+		mgm := getTopology().mgmForProject(project.name)
+		q := getEOSProjectQuota(mgm, project.owner)
+		files = newProjectFiles(files, q)
+
+		threshold := getProjectQuotaApprovalThreshold()
+		if bytes > uint64(q.AvailableBytes) && bytes > threshold {
+			if !force || ticket == "" {
+				fmt.Fprintf(os.Stderr, "Error: increases above %s need --force and --ticket\n", humanize.Bytes(threshold))
+				os.Exit(1)
+			}
+		}
+		checkAuditLog()
+
+		cols := []string{"NAME", "OWNER", "MGM", "USED", "MAX", "NEW_MAX", "MAX_FILES", "NEW_MAX_FILES"}
+		rows := [][]string{{project.name, project.owner, mgm, humanQuota(q.UsedBytes), humanQuota(q.AvailableBytes), humanize.Bytes(bytes), fmt.Sprintf("%d", q.AvailableInodes), fmt.Sprintf("%d", files)}}
+		pretty(cols, rows)
+
+		if !yes {
+			if !askForConfirmation("Are you sure to set the quota?\n") {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*60)
+		defer cancel()
+		if err := getEOS(mgm).SetQuota(ctx, project.owner, getTopology().Project.Prefix+"/", bytes, files); err != nil {
+			er(err)
+		}
+
+		auditLog("project-quota-set", map[string]string{
+			"name":      project.name,
+			"owner":     project.owner,
+			"mgm":       mgm,
+			"old_bytes": fmt.Sprintf("%d", q.AvailableBytes),
+			"new_bytes": fmt.Sprintf("%d", bytes),
+			"old_files": fmt.Sprintf("%d", q.AvailableInodes),
+			"new_files": fmt.Sprintf("%d", files),
+			"ticket":    ticket,
+			"forced":    fmt.Sprintf("%t", force),
+		})
+	},
+}
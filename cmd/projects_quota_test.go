@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/spf13/viper"
+)
+
+func TestNewProjectFiles(t *testing.T) {
+	type args struct {
+		files   uint64
+		current int
+	}
+	tuples := map[args]string{
+		{files: 500, current: 2000}: "500",
+		{files: 0, current: 2000}:   "2000",
+		// a project without quota gets the default, not an inode limit of zero
+		{files: 0, current: 0}: "1000000",
+	}
+	for a, expected := range tuples {
+		got := fmt.Sprintf("%d", newProjectFiles(a.files, &eosclient.QuotaInfo{AvailableInodes: a.current}))
+		if got != expected {
+			t.Fatalf("got:%s expected:%s", got, expected)
+		}
+	}
+
+	viper.Set("project_default_files", 300)
+	defer viper.Set("project_default_files", 0)
+	if got := newProjectFiles(0, &eosclient.QuotaInfo{}); got != 300 {
+		t.Fatalf("got:%d expected:300", got)
+	}
+}
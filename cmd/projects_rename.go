@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	projectCmd.AddCommand(projectRenameCmd)
+
+	projectRenameCmd.Flags().BoolP("dry-run", "", false, "only show the plan")
+	projectRenameCmd.Flags().BoolP("yes", "y", false, "renames without confirmation")
+	projectRenameCmd.Flags().BoolP("status", "s", false, "show progress while listing the project for shares")
+}
+
+var projectRenameCmd = &cobra.Command{
+	Use:   "rename <project-name> <new-name>",
+	Short: "Renames a project in EOS and in the db and checks its shares still resolve",
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+		status, _ := cmd.Flags().GetBool("status")
+		if len(args) != 2 {
+			exit(cmd)
+		}
+
+		project, err := getProject(strings.TrimSpace(args[0]))
+		if err != nil {
+			er(err)
+		}
+		newName := strings.TrimSpace(args[1])
+		if !projectNameRegexp.MatchString(newName) {
+			er(fmt.Sprintf("invalid project name %q, only lowercase letters, digits, - and _ are allowed", newName))
+		}
+		for _, p := range getProjects(All{}) {
+			if p.name == newName || p.rel == getProjectRelPath(newName) {
+				er(fmt.Sprintf("project %q already exists", newName))
+			}
+		}
+
+		// a rename is a namespace operation, it cannot move data between instances
+		mgm := getTopology().mgmForProject(project.name)
+		if newMGM := getTopology().mgmForProject(newName); newMGM != mgm {
+			er(fmt.Sprintf("%s is on %s and %s would be on %s, renames across MGMs are not supported", project.name, mgm, newName, newMGM))
+		}
+
+		client := getEOS(mgm)
+		oldPath := path.Join(getTopology().Project.Prefix, project.rel)
+		newPath := getTopology().Project.path(newName)
+		newRel := getProjectRelPath(newName)
+		ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+		_, err = client.GetFileInfoByPath(ctx, "root", newPath)
+		cancel()
+		if err == nil {
+			er(fmt.Sprintf("%s already exists in EOS", newPath))
+		}
+
+		shares, err := getSharesByPath(oldPath, true, status)
+		if err != nil {
+			er(err)
+		}
+
+		steps := []*provisionStep{
+			{
+				name: fmt.Sprintf("move %s to %s on %s", oldPath, newPath, mgm),
+				do: withEOSTimeout(func(ctx context.Context) error {
+					return client.Rename(ctx, "root", oldPath, newPath)
+				}),
+				undo: withEOSTimeout(func(ctx context.Context) error {
+					return client.Rename(ctx, "root", newPath, oldPath)
+				}),
+			},
+			{
+				name: fmt.Sprintf("rename project %s (%s) to %s (%s) in the db", project.name, project.rel, newName, newRel),
+				do:   func() error { return renameProject(project.name, newName, newRel) },
+				undo: func() error { return renameProject(newName, project.name, project.rel) },
+			},
+		}
+
+		for i, s := range steps {
+			fmt.Printf("%d. %s\n", i+1, s.name)
+		}
+		fmt.Printf("%d shares to verify\n", len(shares))
+		// the e-groups are not renamed, the commands read them from the sys.acl
+		entries, err := getProjectACLs(project)
+		if err != nil {
+			er(err)
+		}
+		for _, e := range entries {
+			fmt.Printf("note: the ACLs keep referencing %s\n", e.Qualifier)
+		}
+
+		if dryRun {
+			return
+		}
+		checkAuditLog()
+
+		if !yes {
+			if !askForConfirmation(fmt.Sprintf("Are you sure to rename project %s to %s?\n", project.name, newName)) {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		if err := runSteps(steps); err != nil {
+			er(err)
+		}
+		getPathResolver().invalidate(oldPath)
+
+		auditLog("project-rename", map[string]string{
+			"name":     project.name,
+			"new_name": newName,
+			"path":     oldPath,
+			"new_path": newPath,
+			"owner":    project.owner,
+		})
+
+		broken := verifyRenamedShares(shares, newPath)
+		if len(broken) > 0 {
+			cols := []string{"ID", "OWNER", "TYPE", "SHARE_WITH", "PATH"}
+			rows := [][]string{}
+			for _, b := range broken {
+				rows = append(rows, []string{fmt.Sprintf("%d", b.share.ID), b.share.UIDOwner, b.share.HumanType(), b.share.HumanShareWith(), b.path})
+			}
+			pretty(cols, rows)
+			fmt.Fprintf(os.Stderr, "Error: %d of %d shares do not resolve under %s\n", len(broken), len(shares), newPath)
+			os.Exit(1)
+		}
+		fmt.Printf("project %s renamed to %s, %d shares verified\n", project.name, newName, len(shares))
+	},
+}
+
+type brokenShare struct {
+	share *dbShare
+	path  string
+}
+
+// verifyRenamedShares returns the shares whose inode does not resolve under the new path.
+func verifyRenamedShares(shares []*dbShare, newPath string) []*brokenShare {
+	broken := []*brokenShare{}
+	resolver := getPathResolver()
+	for _, s := range shares {
+		p, err := resolver.resolve(getCtx(), s.Prefix, s.ItemSource, true)
+		if err != nil {
+			broken = append(broken, &brokenShare{share: s, path: err.Error()})
+			continue
+		}
+		p = path.Clean(p)
+		if p != newPath && !strings.HasPrefix(p, newPath+"/") {
+			broken = append(broken, &brokenShare{share: s, path: p})
+		}
+	}
+	return broken
+}
+
+func renameProject(name, newName, newRel string) error {
+	db := getDB()
+
+	stmtString := "UPDATE cernbox_project_mapping SET project_name=?, eos_relative_path=? WHERE project_name=?"
+	stmt, err := db.Prepare(stmtString)
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(newName, newRel, name)
+	if err != nil {
+		return err
+	}
+	// no row renamed means the mapping changed meanwhile, the move must be undone
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("project %s not found in the db", name)
+	}
+	return nil
+}
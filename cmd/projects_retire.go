@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The retirement ledger is a bbolt database, set with project_retirement_db
+// in the config, with one retirementRecord per project in the "Retirements"
+// bucket keyed by project name. Retiring projects are read-only and wait for
+// the grace period to end, retired ones have been archived and removed from the db.
+//
+// EX.
+// "cernbox" -> {State: "retiring", Path: "/eos/project/c/cernbox", GraceUntil: "2020-12-09T15:07:28+01:00", ...}
+
+const projectRetirementBucket = "Retirements"
+
+const (
+	retirementRetiring = "retiring"
+	retirementRetired  = "retired"
+)
+
+func init() {
+	projectCmd.AddCommand(projectRetireCmd)
+
+	projectRetireCmd.Flags().IntP("grace", "", 30, "days before the project can be finalized")
+	projectRetireCmd.Flags().BoolP("finalize", "", false, "archive the retiring projects whose grace period ended")
+	projectRetireCmd.Flags().BoolP("list", "", false, "list the retirement ledger")
+	projectRetireCmd.Flags().BoolP("dry-run", "", false, "only show what would be done")
+	projectRetireCmd.Flags().BoolP("no-email", "", false, "do not email the owner and the admins")
+	projectRetireCmd.Flags().BoolP("yes", "y", false, "retires without confirmation")
+}
+
+type retirementRecord struct {
+	Name        string    `json:"name"`
+	Rel         string    `json:"rel"`
+	Owner       string    `json:"owner"`
+	Path        string    `json:"path"`
+	MGM         string    `json:"mgm"`
+	State       string    `json:"state"`
+	Started     time.Time `json:"started"`
+	GraceUntil  time.Time `json:"grace_until"`
+	Finalized   time.Time `json:"finalized"`
+	OriginalACL string    `json:"original_acl"`
+	ArchivePath string    `json:"archive_path,omitempty"`
+
+	// FolderACLs is the original sys.acl of every folder of the project, keyed by path
+	FolderACLs map[string]string `json:"folder_acls,omitempty"`
+}
+
+var projectRetireCmd = &cobra.Command{
+	Use:   "retire <project-name> | --finalize [<project-name>] | --list",
+	Short: "Retires a project: makes it read-only and, after the grace period, archives it and removes it from the db",
+	Run: func(cmd *cobra.Command, args []string) {
+		grace, _ := cmd.Flags().GetInt("grace")
+		finalize, _ := cmd.Flags().GetBool("finalize")
+		list, _ := cmd.Flags().GetBool("list")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		noEmail, _ := cmd.Flags().GetBool("no-email")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		switch {
+		case list && !finalize && len(args) == 0:
+			printRetirements(getRetirements())
+		case finalize && !list && len(args) <= 1:
+			name := ""
+			if len(args) == 1 {
+				name = strings.TrimSpace(args[0])
+			}
+			finalizeRetirements(name, dryRun)
+		case !list && !finalize && len(args) == 1 && grace >= 0:
+			retireProject(strings.TrimSpace(args[0]), grace, dryRun, noEmail, yes)
+		default:
+			exit(cmd)
+		}
+	},
+}
+
+func retireProject(nameOrPath string, grace int, dryRun, noEmail, yes bool) {
+	project, err := getProject(nameOrPath)
+	if err != nil {
+		er(err)
+	}
+	for _, r := range getRetirements() {
+		if r.Name == project.name {
+			er(fmt.Sprintf("project %q is already %s", project.name, r.State))
+		}
+	}
+
+	now := time.Now()
+	r := &retirementRecord{
+		Name:       project.name,
+		Rel:        project.rel,
+		Owner:      project.owner,
+		Path:       path.Join(getTopology().Project.Prefix, project.rel),
+		MGM:        getTopology().mgmForProject(project.name),
+		State:      retirementRetiring,
+		Started:    now,
+		GraceUntil: now.AddDate(0, 0, grace),
+	}
+
+	client := getEOS(r.MGM)
+	r.FolderACLs, err = getFolderACLs(getCtx(), client, r.Path)
+	if err != nil {
+		er(err)
+	}
+	r.OriginalACL = r.FolderACLs[r.Path]
+	readOnly, err := readOnlyACL(r.OriginalACL)
+	if err != nil {
+		er(err)
+	}
+	// fail on any unparsable sys.acl before changing anything
+	for p, sysACL := range r.FolderACLs {
+		if _, err := readOnlyACL(sysACL); err != nil {
+			er(fmt.Sprintf("sys.acl of %s: %v", p, err))
+		}
+	}
+
+	cols := []string{"NAME", "PATH", "OWNER", "ACL", "NEW_ACL", "FOLDERS", "GRACE_UNTIL"}
+	rows := [][]string{{r.Name, r.Path, r.Owner, r.OriginalACL, readOnly, fmt.Sprintf("%d", len(r.FolderACLs)), r.GraceUntil.Format(dayLayout)}}
+	pretty(cols, rows)
+
+	if dryRun {
+		return
+	}
+	checkAuditLog()
+	openRetirementLedger().Close() // fail before changing anything
+
+	if !yes {
+		if !askForConfirmation(fmt.Sprintf("Are you sure to retire project %s?\n", r.Name)) {
+			fmt.Fprintf(os.Stderr, "Aborted\n")
+			os.Exit(1)
+		}
+	}
+
+	// the ledger keeps the original ACLs even if the run is interrupted
+	saveRetirement(r)
+	if err := setReadOnlyACLs(getCtx(), client, r.FolderACLs); err != nil {
+		deleteRetirement(r.Name)
+		er(err)
+	}
+
+	auditLog("project-retire", map[string]string{
+		"name":        r.Name,
+		"path":        r.Path,
+		"owner":       r.Owner,
+		"acl":         r.OriginalACL,
+		"grace_until": r.GraceUntil.Format(dayLayout),
+	})
+
+	if !noEmail {
+		notifyRetirement(r)
+	}
+	fmt.Printf("project %s is read-only and can be finalized after %s\n", r.Name, r.GraceUntil.Format(dayLayout))
+}
+
+// getFolderACLs returns the sys.acl of the folder and of all its sub-folders, keyed by path.
+func getFolderACLs(ctx context.Context, client Storage, dir string) (map[string]string, error) {
+	tctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	fi, err := client.GetFileInfoByPath(tctx, "root", dir)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	acls := map[string]string{path.Clean(fi.File): fi.SysACL}
+	dirs := []string{fi.File}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		tctx, cancel := context.WithTimeout(ctx, time.Second*30)
+		mds, err := client.List(tctx, "root", dir)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, md := range mds {
+			if md.IsDir {
+				acls[path.Clean(md.File)] = md.SysACL
+				dirs = append(dirs, md.File)
+			}
+		}
+	}
+	return acls, nil
+}
+
+// setReadOnlyACLs rewrites the own sys.acl of every folder with read-only
+// permissions, so the ACLs of the shared sub-folders are kept. On error the
+// folders already changed get their original sys.acl back.
+func setReadOnlyACLs(ctx context.Context, client Storage, folderACLs map[string]string) error {
+	changed := []string{}
+	setACL := func(p, sysACL string) error {
+		tctx, cancel := context.WithTimeout(ctx, time.Second*30)
+		defer cancel()
+		attr := &eosclient.Attribute{Type: eosclient.SystemAttr, Key: "acl", Val: sysACL}
+		return client.SetAttr(tctx, "root", attr, false, p)
+	}
+
+	for p, sysACL := range folderACLs {
+		readOnly, err := readOnlyACL(sysACL)
+		if err == nil && readOnly != sysACL {
+			err = setACL(p, readOnly)
+		}
+		if err == nil {
+			changed = append(changed, p)
+			continue
+		}
+
+		for _, c := range changed {
+			if uerr := setACL(c, folderACLs[c]); uerr != nil {
+				fmt.Fprintf(os.Stderr, "error restoring the sys.acl of %s to %q, please fix it manually: %+v\n", c, folderACLs[c], uerr)
+			}
+		}
+		return fmt.Errorf("setting the read-only sys.acl of %s: %v", p, err)
+	}
+	return nil
+}
+
+// readOnlyACL returns the sys.acl with the write permissions replaced by read-only ones.
+func readOnlyACL(sysACL string) (string, error) {
+	acls, err := acl.Parse(sysACL, acl.ShortTextForm)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range acls.Entries {
+		if aclAllowsWrite(e.Permissions) {
+			e.Permissions = aclPermReadOnly
+		}
+	}
+	return acls.Serialize(), nil
+}
+
+func finalizeRetirements(name string, dryRun bool) {
+	now := time.Now()
+	due := []*retirementRecord{}
+	found := false
+	for _, r := range getRetirements() {
+		if name != "" && r.Name != name {
+			continue
+		}
+		found = true
+		if r.State != retirementRetiring {
+			continue
+		}
+		if now.Before(r.GraceUntil) {
+			if name != "" {
+				er(fmt.Sprintf("the grace period of project %q ends on %s", name, r.GraceUntil.Format(dayLayout)))
+			}
+			continue
+		}
+		r.ArchivePath = path.Join(path.Dir(r.Path), ".archive", fmt.Sprintf("%s-%s", path.Base(r.Path), now.Format("20060102")))
+		due = append(due, r)
+	}
+	if name != "" && !found {
+		er(fmt.Sprintf("project %q is not retiring", name))
+	}
+
+	cols := []string{"NAME", "PATH", "OWNER", "GRACE_UNTIL", "ARCHIVE_PATH"}
+	rows := [][]string{}
+	for _, r := range due {
+		rows = append(rows, []string{r.Name, r.Path, r.Owner, r.GraceUntil.Format(dayLayout), r.ArchivePath})
+	}
+	pretty(cols, rows)
+
+	if dryRun || len(due) == 0 {
+		return
+	}
+	checkAuditLog()
+
+	for _, r := range due {
+		if err := runSteps(retirementSteps(r)); err != nil {
+			fmt.Fprintf(os.Stderr, "error finalizing project %s: %+v\n", r.Name, err)
+			continue
+		}
+		// the shared files now live under the archive
+		getPathResolver().invalidate(r.Path)
+		r.State = retirementRetired
+		r.Finalized = time.Now()
+		saveRetirement(r)
+		auditLog("project-retire-finalize", map[string]string{
+			"name":    r.Name,
+			"path":    r.Path,
+			"owner":   r.Owner,
+			"archive": r.ArchivePath,
+		})
+	}
+}
+
+func retirementSteps(r *retirementRecord) []*provisionStep {
+	client := getEOS(r.MGM)
+	node := getTopology().Project.Prefix + "/"
+	var quota *eosclient.QuotaInfo
+
+	return []*provisionStep{
+		{
+			name: fmt.Sprintf("create %s", path.Dir(r.ArchivePath)),
+			do: withEOSTimeout(func(ctx context.Context) error {
+				return client.CreateDir(ctx, "root", path.Dir(r.ArchivePath))
+			}),
+		},
+		{
+			name: fmt.Sprintf("move %s to %s", r.Path, r.ArchivePath),
+			do: withEOSTimeout(func(ctx context.Context) error {
+				return client.Rename(ctx, "root", r.Path, r.ArchivePath)
+			}),
+			undo: withEOSTimeout(func(ctx context.Context) error {
+				return client.Rename(ctx, "root", r.ArchivePath, r.Path)
+			}),
+		},
+		{
+			name: fmt.Sprintf("remove quota of %s on %s", r.Owner, node),
+			do: withEOSTimeout(func(ctx context.Context) error {
+				q, err := client.GetQuota(ctx, r.Owner, node)
+				if err != nil {
+					return err
+				}
+				quota = q
+				return client.RmQuota(ctx, r.Owner, node)
+			}),
+			undo: withEOSTimeout(func(ctx context.Context) error {
+				return client.SetQuota(ctx, r.Owner, node, uint64(quota.AvailableBytes), uint64(quota.AvailableInodes))
+			}),
+		},
+		{
+			name: fmt.Sprintf("delete project %s from the db", r.Name),
+			do:   func() error { return deleteProject(&projectSpace{name: r.Name, rel: r.Rel, owner: r.Owner}) },
+		},
+	}
+}
+
+func notifyRetirement(r *retirementRecord) {
+	lc := getLDAP()
+	defer lc.Close()
+
+	to := []string{}
+	if ui := getUser(lc, r.Owner); ui.Mail != "" {
+		to = append(to, ui.Mail)
+	}
+	admins := []string{}
+	if egroup, err := getProjectAdminEgroup(&projectSpace{name: r.Name, rel: r.Rel, owner: r.Owner}); err != nil {
+		fmt.Fprintf(os.Stderr, "error reading the admin e-group of %s, only the owner is notified: %+v\n", r.Name, err)
+	} else {
+		admins, _ = getEgroupMembers(lc, egroup)
+	}
+	for _, dn := range admins {
+		if cn := extractCN(dn); cn != "" {
+			if ui := getUser(lc, cn); ui.Mail != "" && !isInList(to, ui.Mail) {
+				to = append(to, ui.Mail)
+			}
+		}
+	}
+	if len(to) == 0 {
+		fmt.Fprintf(os.Stderr, "no email found for the owner and admins of %s\n", r.Name)
+		return
+	}
+
+	msg := "From: " + getEmailSender() + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: CERNBox: project " + r.Name + " is being retired\r\n" +
+		"\r\n" +
+		"Dear project administrators,\r\n\r\n" +
+		"The CERNBox project " + r.Name + " (" + r.Path + ") is now read-only and will be archived after " + r.GraceUntil.Format(dayLayout) + ".\r\n\r\n" +
+		"Please copy any data you still need before that day or contact the CERNBox service if the project should be kept.\r\n\r\n" +
+		"The CERNBox team\r\n"
+	if err := sendEmailTo(to, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "error sending email to %s: %+v\n", strings.Join(to, ", "), err)
+	}
+}
+
+func printRetirements(records []*retirementRecord) {
+	cols := []string{"NAME", "STATE", "OWNER", "PATH", "STARTED", "GRACE_UNTIL", "FINALIZED", "ARCHIVE_PATH"}
+	rows := [][]string{}
+	for _, r := range records {
+		finalized := "-"
+		if !r.Finalized.IsZero() {
+			finalized = r.Finalized.Format(dayLayout)
+		}
+		archive := r.ArchivePath
+		if archive == "" {
+			archive = "-"
+		}
+		rows = append(rows, []string{r.Name, r.State, r.Owner, r.Path, r.Started.Format(dayLayout), r.GraceUntil.Format(dayLayout), finalized, archive})
+	}
+	pretty(cols, rows)
+}
+
+func getProjectRetirementDB() string {
+	return viper.GetString("project_retirement_db")
+}
+
+func openRetirementLedger() *bolt.DB {
+	file := getProjectRetirementDB()
+	if file == "" {
+		er("please set project_retirement_db in the config")
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second * 30})
+	if err != nil {
+		er(err)
+	}
+	return db
+}
+
+func saveRetirement(r *retirementRecord) {
+	db := openRetirementLedger()
+	defer db.Close()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		er(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(projectRetirementBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(r.Name), data)
+	})
+	if err != nil {
+		er(err)
+	}
+}
+
+func deleteRetirement(name string) {
+	db := openRetirementLedger()
+	defer db.Close()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(projectRetirementBucket))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+	if err != nil {
+		er(err)
+	}
+}
+
+// getRetirements returns the ledger, empty when project_retirement_db is not set.
+func getRetirements() []*retirementRecord {
+	records := []*retirementRecord{}
+	if getProjectRetirementDB() == "" {
+		return records
+	}
+
+	db := openRetirementLedger()
+	defer db.Close()
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(projectRetirementBucket))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			r := &retirementRecord{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+	})
+	if err != nil {
+		er(err)
+	}
+	return records
+}
+
+// getRetiredPaths returns the paths of the retired projects, excluded from the accounting.
+func getRetiredPaths() map[string]bool {
+	paths := map[string]bool{}
+	for _, r := range getRetirements() {
+		if r.State == retirementRetired {
+			paths[path.Clean(r.Path)] = true
+		}
+	}
+	return paths
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+)
+
+func TestSetReadOnlyACLs(t *testing.T) {
+	s, err := getFakeStorage("", "root://eosproject-c.cern.ch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	root := "/eos/project/c/cernbox"
+	writers := "egroup:cernbox-project-cernbox-writers:rwx+d,egroup:cernbox-project-cernbox-readers:rx"
+	tuples := map[string]string{
+		root:                   writers,
+		root + "/docs":         writers,
+		root + "/shared":       writers + ",u:1234:rwx+d",
+		root + "/shared/inbox": writers + ",u:1234:rwx+d,egroup:atlas-team:rx",
+	}
+	for _, p := range []string{root, root + "/docs", root + "/shared", root + "/shared/inbox"} {
+		if err := s.CreateDir(ctx, "root", p); err != nil {
+			t.Fatal(err)
+		}
+		attr := &eosclient.Attribute{Type: eosclient.SystemAttr, Key: "acl", Val: tuples[p]}
+		if err := s.SetAttr(ctx, "root", attr, false, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acls, err := getFolderACLs(ctx, s, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acls) != len(tuples) {
+		t.Fatalf("got:%d expected:%d", len(acls), len(tuples))
+	}
+	for p, expected := range tuples {
+		if acls[p] != expected {
+			t.Fatalf("got:%s expected:%s", acls[p], expected)
+		}
+	}
+
+	if err := setReadOnlyACLs(ctx, s, acls); err != nil {
+		t.Fatal(err)
+	}
+
+	// every folder keeps its own entries, the share on shared is read-only too
+	readOnly := "egroup:cernbox-project-cernbox-writers:rx,egroup:cernbox-project-cernbox-readers:rx"
+	expected := map[string]string{
+		root:                   readOnly,
+		root + "/docs":         readOnly,
+		root + "/shared":       readOnly + ",u:1234:rx",
+		root + "/shared/inbox": readOnly + ",u:1234:rx,egroup:atlas-team:rx",
+	}
+	for p, e := range expected {
+		fi, err := s.GetFileInfoByPath(ctx, "root", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.SysACL != e {
+			t.Fatalf("got:%s expected:%s", fi.SysACL, e)
+		}
+	}
+}
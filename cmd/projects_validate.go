@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/ldap.v3"
+)
+
+func init() {
+	projectCmd.AddCommand(projectValidateCmd)
+
+	projectValidateCmd.Flags().IntP("concurrency", "c", 20, "use up to <n> concurrent LDAP lookups")
+	projectValidateCmd.Flags().BoolP("problems", "", false, "only show the projects with problems")
+	projectValidateCmd.Flags().BoolP("no-charging", "", false, "do not check the charge group with the charging service")
+}
+
+type projectHealth struct {
+	project      *projectSpace
+	ownerType    string
+	accountOwner string
+	adminEgroup  string
+	admins       int
+	chargeGroup  string
+	problems     []string
+}
+
+var projectValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the owner of every project against LDAP and the charging service",
+	Run: func(cmd *cobra.Command, args []string) {
+		conc, _ := cmd.Flags().GetInt("concurrency")
+		problemsOnly, _ := cmd.Flags().GetBool("problems")
+		noCharging, _ := cmd.Flags().GetBool("no-charging")
+
+		projects := getProjects(All{})
+
+		lc := getLDAP()
+		defer lc.Close()
+		healths := validateProjects(lc, projects, conc)
+
+		if !noCharging {
+			charges := getCharging(nil)
+			for _, h := range healths {
+				ci, ok := charges[h.project.owner]
+				if !ok || ci.ChargeGroup == "" || ci.ChargeGroup == "Unknown" {
+					h.problems = append(h.problems, "no charge group")
+					continue
+				}
+				h.chargeGroup = ci.ChargeGroup
+			}
+		}
+
+		cols := []string{"NAME", "OWNER", "TYPE", "ACCOUNT_OWNER", "ADMIN_EGROUP", "ADMINS", "CHARGE_GROUP", "STATUS"}
+		rows := [][]string{}
+		for _, h := range healths {
+			if problemsOnly && len(h.problems) == 0 {
+				continue
+			}
+			status := "ok"
+			if len(h.problems) > 0 {
+				status = strings.Join(h.problems, ", ")
+			}
+			rows = append(rows, []string{h.project.name, h.project.owner, h.ownerType, h.accountOwner, h.adminEgroup, fmt.Sprintf("%d", h.admins), h.chargeGroup, status})
+		}
+		pretty(cols, rows)
+	},
+}
+
+func validateProjects(lc *ldap.Conn, projects []*projectSpace, concurrency int) []*projectHealth {
+	var throttle = make(chan int, concurrency)
+	var wg sync.WaitGroup
+
+	healths := make([]*projectHealth, len(projects))
+	spin := NewDeterminatedSpinStatus("Validating projects", len(projects))
+	spin.Start()
+	for i, p := range projects {
+		throttle <- 1
+		wg.Add(1)
+
+		go func(i int, p *projectSpace) {
+			defer wg.Done()
+			defer func() {
+				<-throttle
+			}()
+
+			healths[i] = validateProject(lc, p)
+			spin.Update(1)
+		}(i, p)
+	}
+	wg.Wait()
+	spin.Done()
+	return healths
+}
+
+func validateProject(lc *ldap.Conn, p *projectSpace) *projectHealth {
+	h := &projectHealth{
+		project:      p,
+		ownerType:    "-",
+		accountOwner: "-",
+		adminEgroup:  fmt.Sprintf("cernbox-project-%s-admins", p.name),
+		chargeGroup:  "-",
+	}
+
+	ui := getUserFull(lc, p.owner)
+	switch {
+	case ui.Account == "":
+		h.problems = append(h.problems, "owner not in LDAP")
+	case ui.AccountType != "Service":
+		h.ownerType = ui.AccountType
+		h.problems = append(h.problems, "owner is not a service account")
+	default:
+		h.ownerType = ui.AccountType
+		if ui.Disabled {
+			h.problems = append(h.problems, "owner disabled")
+		}
+		if ui.AccountOwner == nil || ui.AccountOwner.Account == "" {
+			h.problems = append(h.problems, "no account owner")
+		} else {
+			h.accountOwner = ui.AccountOwner.Account
+			if ui.AccountOwner.Disabled {
+				h.problems = append(h.problems, "account owner disabled")
+			}
+		}
+	}
+
+	if egroup, err := getProjectAdminEgroup(p); err != nil {
+		h.problems = append(h.problems, "sys.acl not readable")
+	} else {
+		h.adminEgroup = egroup
+	}
+
+	members, exists := getEgroupMembers(lc, h.adminEgroup)
+	h.admins = len(members)
+	if !exists {
+		h.problems = append(h.problems, "no admin egroup")
+	} else if len(members) == 0 {
+		h.problems = append(h.problems, "empty admin egroup")
+	}
+	return h
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// output format for tables, set with the global --output flag
+var outputFormat string
+
+var outputFormats = []string{"table", "json", "csv", "yaml", "ndjson"}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "table", "output format: "+strings.Join(outputFormats, "|"))
+}
+
+func validOutputFormat(format string) bool {
+	return isInList(outputFormats, format)
+}
+
+// columnKey returns the stable key used for a table column in
+// structured formats: SHARE_WITH => share_with, RelativePath => relative_path
+func columnKey(col string) string {
+	var b strings.Builder
+	runes := []rune(col)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune('_')
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	// collapse repeated separators
+	key := b.String()
+	for strings.Contains(key, "__") {
+		key = strings.ReplaceAll(key, "__", "_")
+	}
+	return strings.Trim(key, "_")
+}
+
+// tableRecord is a table row that keeps the column order when encoded.
+type tableRecord struct {
+	keys   []string
+	values []string
+}
+
+func newRecords(cols []string, rows [][]string) []*tableRecord {
+	keys := make([]string, len(cols))
+	for i, c := range cols {
+		keys[i] = columnKey(c)
+	}
+
+	records := make([]*tableRecord, 0, len(rows))
+	for _, row := range rows {
+		values := make([]string, len(keys))
+		copy(values, row)
+		records = append(records, &tableRecord{keys: keys, values: values})
+	}
+	return records
+}
+
+func (r *tableRecord) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, k := range r.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+func (r *tableRecord) MarshalYAML() (interface{}, error) {
+	m := make(yaml.MapSlice, 0, len(r.keys))
+	for i, k := range r.keys {
+		m = append(m, yaml.MapItem{Key: k, Value: r.values[i]})
+	}
+	return m, nil
+}
+
+// render writes the table in the given format.
+func render(w io.Writer, format string, cols []string, rows [][]string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newRecords(cols, rows))
+
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, r := range newRecords(cols, rows) {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "yaml":
+		data, err := yaml.Marshal(newRecords(cols, rows))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		keys := make([]string, len(cols))
+		for i, c := range cols {
+			keys[i] = columnKey(c)
+		}
+		cw.Write(keys)
+		for _, r := range newRecords(cols, rows) {
+			cw.Write(r.values)
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "table", "":
+		table := tablewriter.NewWriter(w)
+		table.SetHeader(cols)
+		table.SetAutoWrapText(false)
+		table.SetAutoFormatHeaders(true)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetCenterSeparator("")
+		table.SetColumnSeparator("")
+		table.SetRowSeparator("")
+		table.SetHeaderLine(false)
+		table.SetBorder(false)
+		table.SetTablePadding("\t") // pad with tabs
+		table.SetNoWhiteSpace(true)
+		table.AppendBulk(rows) // Add Bulk Data
+		table.Render()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// save writes the table to the file, the files are read by other systems
+// so they always keep the table format whatever --output is.
+func save(cols []string, rows [][]string, file string) {
+	// make sure all parent directories exist
+	os.MkdirAll(path.Dir(file), 0755)
+	fd, err := os.Create(file)
+	if err != nil {
+		er(err)
+	}
+	defer fd.Close()
+	if err := render(fd, "table", cols, rows); err != nil {
+		er(err)
+	}
+}
+
+// tableOutputOnly stops the commands printing several tables or other text
+// on stdout, their output cannot be a single JSON or YAML document.
+func tableOutputOnly(cmd *cobra.Command) {
+	if outputFormat != "table" {
+		er(fmt.Sprintf("%s only supports --output table", cmd.CommandPath()))
+	}
+}
+
+func pretty(cols []string, rows [][]string) {
+	if err := render(os.Stdout, outputFormat, cols, rows); err != nil {
+		er(err)
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestColumnKey(t *testing.T) {
+	tuples := map[string]string{
+		"SHARE_WITH":   "share_with",
+		"RelativePath": "relative_path",
+		"MAXBYTESH":    "maxbytesh",
+		"Owner":        "owner",
+		"GROWTH %":     "growth",
+	}
+
+	for col, expected := range tuples {
+		if got := columnKey(col); got != expected {
+			t.Fatalf("got:%s expected:%s", got, expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	cols := []string{"ID", "SHARE_WITH", "RelativePath"}
+	rows := [][]string{{"1", "gonzalhu", "c/cernbox"}, {"2", "cernbox-admins", "s/ski club"}}
+
+	tuples := map[string]string{
+		"json":   "[\n  {\n    \"id\": \"1\",\n    \"share_with\": \"gonzalhu\",\n    \"relative_path\": \"c/cernbox\"\n  },\n  {\n    \"id\": \"2\",\n    \"share_with\": \"cernbox-admins\",\n    \"relative_path\": \"s/ski club\"\n  }\n]\n",
+		"ndjson": "{\"id\":\"1\",\"share_with\":\"gonzalhu\",\"relative_path\":\"c/cernbox\"}\n{\"id\":\"2\",\"share_with\":\"cernbox-admins\",\"relative_path\":\"s/ski club\"}\n",
+		"csv":    "id,share_with,relative_path\n1,gonzalhu,c/cernbox\n2,cernbox-admins,s/ski club\n",
+		"yaml":   "- id: \"1\"\n  share_with: gonzalhu\n  relative_path: c/cernbox\n- id: \"2\"\n  share_with: cernbox-admins\n  relative_path: s/ski club\n",
+	}
+
+	for format, expected := range tuples {
+		buf := &bytes.Buffer{}
+		if err := render(buf, format, cols, rows); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != expected {
+			t.Fatalf("format:%s got:%q expected:%q", format, got, expected)
+		}
+	}
+
+	if err := render(&bytes.Buffer{}, "xml", cols, rows); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
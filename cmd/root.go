@@ -7,15 +7,12 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"strings"
 
 	"github.com/cs3org/reva/pkg/appctx"
-	"github.com/cs3org/reva/pkg/eosclient"
 	"github.com/go-redis/redis"
 	_ "github.com/go-sql-driver/mysql"
 	homedir "github.com/mitchellh/go-homedir"
-	"github.com/olekukonko/tablewriter"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -36,6 +33,7 @@ var (
 
 // Execute executes the root command.
 func Execute() error {
+	defer closePathResolver()
 	return rootCmd.Execute()
 }
 
@@ -79,6 +77,10 @@ func initConfig() {
 		er(err)
 	}
 
+	if !validOutputFormat(outputFormat) {
+		er("unknown output format: " + outputFormat)
+	}
+
 	logfile := viper.GetString("logfile")
 	log = newLogger(logfile)
 }
@@ -120,25 +122,12 @@ func getLDAP() *ldap.Conn {
 	return l
 }
 
-func getEOS(mgm string) *eosclient.Client {
-	eosClientOpts := &eosclient.Options{
-		URL: mgm,
-	}
-
-	eosClient := eosclient.New(eosClientOpts)
-	return eosClient
+func getEOSForUser(username string) Storage {
+	return getEOS(getTopology().mgmForUser(username))
 }
 
-func getEOSForUser(username string) *eosclient.Client {
-	letter := string(username[0])
-	mgm := fmt.Sprintf("root://eoshome-%s.cern.ch", letter)
-	return getEOS(mgm)
-}
-
-func getEOSForProject(prjname string) *eosclient.Client {
-	letter := string(prjname[0])
-	mgm := fmt.Sprintf("root://eosproject-%s.cern.ch", letter)
-	return getEOS(mgm)
+func getEOSForProject(prjname string) Storage {
+	return getEOS(getTopology().mgmForProject(prjname))
 }
 
 func getProbeUser() (string, string) {
@@ -192,47 +181,6 @@ func saveWith(file string, data []byte) {
 	fd.Write(data)
 }
 
-func save(cols []string, rows [][]string, file string) {
-	// make sure all parent directories exist
-	os.MkdirAll(path.Dir(file), 0755)
-	fd, err := os.Create(file)
-	if err != nil {
-		er(err)
-	}
-	table := tablewriter.NewWriter(fd)
-	table.SetHeader(cols)
-	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(true)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
-	table.SetHeaderLine(false)
-	table.SetBorder(false)
-	table.SetTablePadding("\t") // pad with tabs
-	table.SetNoWhiteSpace(true)
-	table.AppendBulk(rows) // Add Bulk Data
-	table.Render()
-}
-func pretty(cols []string, rows [][]string) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader(cols)
-	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(true)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
-	table.SetHeaderLine(false)
-	table.SetBorder(false)
-	table.SetTablePadding("\t") // pad with tabs
-	table.SetNoWhiteSpace(true)
-	table.AppendBulk(rows) // Add Bulk Data
-	table.Render()
-}
-
 func newLogger(logfile string) *zerolog.Logger {
 	w := getWriter(logfile)
 	zl := zerolog.New(w).With().Timestamp().Caller().Logger()
@@ -1,12 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
-	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +23,7 @@ func init() {
 	shareListCmd.Flags().StringP("token", "t", "", "filter by public link token")
 	shareListCmd.Flags().StringP("share-with", "s", "", "filter by share with (username or egroup)")
 	shareListCmd.Flags().StringP("path", "p", "", "filter by eos path")
+	shareListCmd.Flags().BoolP("recursive", "r", false, "with --path also list the shares of all the files and folders below it")
 	shareListCmd.Flags().BoolP("printpath", "", false, "print EOS path, it can be expensive depending on number of shares")
 	shareListCmd.Flags().IntP("concurrency", "", 100, "use up to <n> concurrent connections to resolve paths")
 	shareListCmd.Flags().BoolP("status", "", false, "shows the status when it is resolving EOS paths")
@@ -90,7 +89,10 @@ var shareTransferCmd = &cobra.Command{
 		}
 		// Only admins can create shares on project spaces.
 		// Check that the new owner is also in the admin e-group.
-		adminGroup := fmt.Sprintf("cernbox-project-%s-admins", projectInfo.name)
+		adminGroup, err := getProjectAdminEgroup(projectInfo)
+		if err != nil {
+			er(err)
+		}
 		groups := getUserGroups(owner)
 		var found bool
 		for _, g := range groups {
@@ -126,9 +128,10 @@ var shareListCmd = &cobra.Command{
 	Args: func(cmd *cobra.Command, args []string) error {
 		status, _ := cmd.Flags().GetBool("status")
 		printpath, _ := cmd.Flags().GetBool("printpath")
+		p, _ := cmd.Flags().GetString("path")
 
-		if !printpath && status {
-			return fmt.Errorf("status available only with printpath option")
+		if !printpath && p == "" && status {
+			return fmt.Errorf("status available only with printpath or path option")
 		}
 
 		return nil
@@ -182,6 +185,18 @@ var shareListCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
+		p, _ := cmd.Flags().GetString("path")
+		p = strings.TrimSpace(p)
+		if p != "" {
+			recursive, _ := cmd.Flags().GetBool("recursive")
+			shares, err := getSharesByPath(p, recursive, status)
+			if err != nil {
+				er(err)
+			}
+			print(shares, printpath, concurrency, status && printpath)
+			os.Exit(0)
+		}
+
 		// with no option -> print all shares
 		shares, err := getAllShares()
 		if err != nil {
@@ -193,8 +208,64 @@ var shareListCmd = &cobra.Command{
 	},
 }
 
+// getSharesByPath returns the shares pointing to the inode of the path,
+// and to the inodes of all its descendants if recursive.
+func getSharesByPath(p string, recursive, status bool) ([]*dbShare, error) {
+	mgm, err := getTopology().mgmForPath(p)
+	if err != nil {
+		return nil, err
+	}
+	client := getEOS(mgm)
+	ctx := getCtx()
+
+	fi, err := client.GetFileInfoByPath(ctx, "root", p)
+	if err != nil {
+		return nil, err
+	}
+
+	inodes := []string{fmt.Sprintf("%d", fi.Inode)}
+	if recursive && fi.IsDir {
+		spin := NewIndeterminatedSpinStatus("Listing " + p)
+		if status {
+			spin.Start()
+		}
+		dirs := []string{fi.File}
+		for len(dirs) > 0 {
+			dir := dirs[0]
+			dirs = dirs[1:]
+			mds, err := client.List(ctx, "root", dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, md := range mds {
+				inodes = append(inodes, fmt.Sprintf("%d", md.Inode))
+				if md.IsDir {
+					dirs = append(dirs, md.File)
+				}
+			}
+		}
+		if status {
+			spin.Done()
+		}
+	}
+
+	candidates, err := getSharesByInodes(inodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// inodes are only unique within an instance
+	shares := []*dbShare{}
+	for _, s := range candidates {
+		if getTopology().mgmForInstance(s.Prefix) == mgm {
+			shares = append(shares, s)
+		}
+	}
+	return shares, nil
+}
+
 func print(shares []*dbShare, printpath bool, concurrency int, status bool) {
-	cols := []string{"ID", "FILEID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "PATH"}
+	cols := []string{"ID", "FILEID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "EXPIRATION", "PATH"}
 	rows := [][]string{}
 	if !printpath {
 		concurrency = 1           // don't use concurrency if we don't resolve paths
@@ -228,7 +299,7 @@ func print(shares []*dbShare, printpath bool, concurrency int, status bool) {
 		limit <- struct{}{}
 		go func(s *dbShare, c chan<- []string) {
 			defer func() { <-limit }()
-			row := []string{fmt.Sprintf("%d", s.ID), s.FileID(), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink()}
+			row := []string{fmt.Sprintf("%d", s.ID), s.FileID(), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), s.HumanExpiration()}
 			if printpath {
 				row = append(row, s.GetPath())
 			}
@@ -257,14 +328,13 @@ type dbShare struct {
 	FileTarget  string
 	State       int
 	Token       string
+	Expiration  string // empty if the share does not expire
 }
 
 func (s *dbShare) FileID() string {
-	v := fmt.Sprintf("%s:%s", s.Prefix, s.ItemSource)
 	// replace internal namespacing for one user friendly.
 	// newproject-c => eosproject-c
-	v = strings.ReplaceAll(v, "new", "eos")
-	return v
+	return fmt.Sprintf("%s:%s", getTopology().canonicalInstance(s.Prefix), s.ItemSource)
 }
 
 func (s *dbShare) PublicLink() string {
@@ -288,6 +358,25 @@ func (s *dbShare) HumanPerm() string {
 	return "read-write"
 }
 
+func (s *dbShare) HumanExpiration() string {
+	if s.Expiration == "" {
+		return "-"
+	}
+	return s.Expiration
+}
+
+// Expired returns true if the share has an expiration before now.
+func (s *dbShare) Expired(now time.Time) bool {
+	if s.Expiration == "" {
+		return false
+	}
+	t, err := time.ParseInLocation(shareExpirationLayout, s.Expiration, time.Local)
+	if err != nil {
+		return false
+	}
+	return t.Before(now)
+}
+
 func (s *dbShare) HumanType() string {
 	if s.ShareType == 0 {
 		return "user-share"
@@ -302,69 +391,72 @@ func (s *dbShare) HumanType() string {
 }
 
 func (s *dbShare) GetPath() string {
-	inode, err := strconv.ParseUint(s.ItemSource, 10, 64)
-	if err != nil {
-		er(err)
-	}
-
-	mgm := fmt.Sprintf("%s.cern.ch", s.Prefix)
-	mgm = strings.ReplaceAll(mgm, "new", "eos")
-	req := fmt.Sprintf("http://%s:8000/proc/user/?mgm.cmd=fileinfo&mgm.path=inode:%d&mgm.file.info.option=--path&mgm.format=fuse", mgm, inode)
-
-	resp, err := http.Get(req)
+	p, err := s.resolvePath(getCtx())
 	if err != nil {
 		return "-"
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// i'll retry
-		// TODO: limit number of retries
-		<-time.After(time.Duration(rand.Intn(100)) * time.Millisecond)
-		return s.GetPath()
-	}
+	return p
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		er(err)
-	}
-	sb := strings.TrimSpace(string(body))
-	if sb == "" {
-		return "-"
-	}
-	return sb[8:]
+// resolvePath resolves the shared inode to its EOS path with the shared path resolver.
+// It returns errInodeNotFound if EOS does not know the inode anymore.
+func (s *dbShare) resolvePath(ctx context.Context) (string, error) {
+	return getPathResolver().resolve(ctx, s.Prefix, s.ItemSource, false)
 }
 
+// shareExpirationLayout is the format of the oc_share expiration datetime.
+const shareExpirationLayout = "2006-01-02 15:04:05"
+
+// shareSelect selects the columns scanned by getShares.
+const shareSelect = "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token, coalesce(expiration, '') as expiration from oc_share"
+
 func getSharesByToken(token string) (shares []*dbShare, err error) {
-	query := "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token from oc_share where token=?"
+	query := shareSelect + " where token=?"
 	args := []interface{}{token}
 
 	return getShares(query, args)
 }
 
 func getSharesByWith(with string) (shares []*dbShare, err error) {
-	query := "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token from oc_share where share_with=?"
+	query := shareSelect + " where share_with=?"
 	args := []interface{}{with}
 
 	return getShares(query, args)
 }
 
 func getSharesByID(id string) (shares []*dbShare, err error) {
-	query := "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token from oc_share where id=?"
+	query := shareSelect + " where id=?"
 	args := []interface{}{id}
 
 	return getShares(query, args)
 }
 
 func getSharesByOwner(owner string) (shares []*dbShare, err error) {
-	query := "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token from oc_share where uid_owner=?"
+	query := shareSelect + " where uid_owner=?"
 	args := []interface{}{owner}
 
 	return getShares(query, args)
 }
 
+func getSharesByInodes(inodes []string) (shares []*dbShare, err error) {
+	for i := 0; i < len(inodes); i += 500 {
+		chunk := inodes[i:min(i+500, len(inodes))]
+		args := make([]interface{}, len(chunk))
+		for j := range chunk {
+			args[j] = chunk[j]
+		}
+		query := shareSelect + fmt.Sprintf(" where item_source in (%s)", placeholders(len(chunk)))
+		s, err := getShares(query, args)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s...)
+	}
+	return
+}
+
 func getAllShares() (shares []*dbShare, err error) {
-	query := "select id, coalesce(uid_owner, '') as uid_owner,  coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, stime, permissions, share_type, coalesce(token, '') as token from oc_share"
+	query := shareSelect
 	return getShares(query, nil)
 }
 
@@ -381,6 +473,7 @@ func getShares(query string, args []interface{}) (shares []*dbShare, err error)
 		stime       int
 		permissions int
 		token       string
+		expiration  string
 	)
 
 	rows, err := db.Query(query, args...)
@@ -390,11 +483,11 @@ func getShares(query string, args []interface{}) (shares []*dbShare, err error)
 	defer rows.Close()
 
 	for rows.Next() {
-		err := rows.Scan(&id, &uidOwner, &shareWith, &prefix, &itemSource, &stime, &permissions, &shareType, &token)
+		err := rows.Scan(&id, &uidOwner, &shareWith, &prefix, &itemSource, &stime, &permissions, &shareType, &token, &expiration)
 		if err != nil {
 			return nil, err
 		}
-		dbShare := &dbShare{ID: id, UIDOwner: uidOwner, Prefix: prefix, ItemSource: itemSource, ShareWith: shareWith, STime: stime, Permissions: permissions, ShareType: shareType, Token: token}
+		dbShare := &dbShare{ID: id, UIDOwner: uidOwner, Prefix: prefix, ItemSource: itemSource, ShareWith: shareWith, STime: stime, Permissions: permissions, ShareType: shareType, Token: token, Expiration: expiration}
 		shares = append(shares, dbShare)
 
 	}
@@ -0,0 +1,376 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/spf13/cobra"
+	"gopkg.in/ldap.v3"
+)
+
+func init() {
+	shareCmd.AddCommand(shareAuditCmd)
+
+	shareAuditCmd.Flags().StringP("owner", "o", "", "only audit the shares of this owner account")
+	shareAuditCmd.Flags().StringP("path", "p", "", "only audit the shares under this eos path")
+	shareAuditCmd.Flags().IntP("concurrency", "", 100, "use up to <n> concurrent connections to resolve paths and ACLs")
+	shareAuditCmd.Flags().BoolP("status", "", false, "shows the status when it is auditing")
+	shareAuditCmd.Flags().StringP("script", "", "", "write a shell script fixing the findings to this file")
+}
+
+// The sys.acl entries CERNBox sets for a share.
+const (
+	aclTypeEgroup    = "egroup"
+	aclPermReadOnly  = "rx"
+	aclPermReadWrite = "rwx+d"
+)
+
+// Audit findings.
+const (
+	findingMissingACL      = "missing-acl"
+	findingWrongPermission = "wrong-permission"
+	findingOrphanACL       = "orphan-acl"
+	findingUnresolvedPath  = "unresolved-path"
+	findingInvalidACL      = "invalid-acl"
+)
+
+type auditFinding struct {
+	Kind     string
+	Share    *dbShare // nil for orphan ACLs
+	Path     string
+	Entry    string // the sys.acl entry found, if any
+	Expected string // the sys.acl entry expected, if any
+}
+
+// auditTarget is a shared file or folder with all its shares.
+type auditTarget struct {
+	mgm    string
+	path   string
+	shares []*dbShare
+
+	acls     *acl.ACLs // current sys.acl
+	findings []*auditFinding
+}
+
+var shareAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Checks that the shares match the EOS ACLs of the shared files",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		prefix, _ := cmd.Flags().GetString("path")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		status, _ := cmd.Flags().GetBool("status")
+		script, _ := cmd.Flags().GetString("script")
+
+		owner = strings.TrimSpace(owner)
+		prefix = strings.TrimSpace(prefix)
+		if concurrency < 1 {
+			exit(cmd)
+		}
+
+		var shares []*dbShare
+		var err error
+		if owner != "" {
+			shares, err = getSharesByOwner(owner)
+		} else {
+			shares, err = getAllShares()
+		}
+		if err != nil {
+			er(err)
+		}
+
+		targets := getAuditTargets(shares)
+		if prefix != "" {
+			prefix = path.Clean(prefix)
+			// only the shares of the instance holding the path can be under it,
+			// the others are skipped before resolving their paths
+			if mgm, err := getTopology().mgmForPath(prefix); err == nil {
+				onMGM := []*auditTarget{}
+				for _, t := range targets {
+					if t.mgm == mgm {
+						onMGM = append(onMGM, t)
+					}
+				}
+				targets = onMGM
+			}
+		}
+		auditShares(targets, prefix, concurrency, status)
+
+		cols := []string{"FINDING", "ID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "FILEID", "PATH", "ACL", "EXPECTED"}
+		rows := [][]string{}
+		for _, t := range targets {
+			for _, f := range t.findings {
+				row := []string{f.Kind, "-", "-", "-", "-", "-", "-", f.Path, f.Entry, f.Expected}
+				if f.Share != nil {
+					s := f.Share
+					row = []string{f.Kind, fmt.Sprintf("%d", s.ID), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.FileID(), f.Path, f.Entry, f.Expected}
+				}
+				rows = append(rows, row)
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i][0] < rows[j][0]
+		})
+		pretty(cols, rows)
+
+		if script != "" {
+			saveAuditScript(script, targets)
+			fmt.Fprintf(os.Stderr, "%s\n", script)
+		}
+	},
+}
+
+// getAuditTargets groups the user and egroup shares by shared file.
+// Public links do not set ACLs.
+func getAuditTargets(shares []*dbShare) []*auditTarget {
+	targets := []*auditTarget{}
+	byFileID := map[string]*auditTarget{}
+	for _, s := range shares {
+		if s.ShareType != 0 && s.ShareType != 1 {
+			continue
+		}
+		t, ok := byFileID[s.FileID()]
+		if !ok {
+			t = &auditTarget{mgm: getTopology().mgmForInstance(s.Prefix)}
+			byFileID[s.FileID()] = t
+			targets = append(targets, t)
+		}
+		t.shares = append(t.shares, s)
+	}
+	return targets
+}
+
+func auditShares(targets []*auditTarget, prefix string, concurrency int, status bool) {
+	lc := getLDAP()
+	defer lc.Close()
+	uids := &uidCache{lc: lc, uids: map[string]string{}, names: map[string]string{}}
+
+	spin := NewDeterminatedSpinStatus("Auditing shared files", len(targets))
+	if status {
+		spin.Start()
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, concurrency) // used to limit the number of concurrent goroutines
+	for _, t := range targets {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(t *auditTarget) {
+			defer func() { <-limit; wg.Done() }()
+			auditTargetACLs(t, prefix, uids)
+			if status {
+				spin.Update(1)
+			}
+		}(t)
+	}
+	wg.Wait()
+	if status {
+		spin.Done()
+	}
+}
+
+func auditTargetACLs(t *auditTarget, prefix string, uids *uidCache) {
+	t.path = t.shares[0].GetPath()
+	if t.path == "-" {
+		if prefix == "" {
+			for _, s := range t.shares {
+				t.findings = append(t.findings, &auditFinding{Kind: findingUnresolvedPath, Share: s, Path: "-"})
+			}
+		}
+		return
+	}
+	if prefix != "" && t.path != prefix && !strings.HasPrefix(t.path, prefix+"/") {
+		return
+	}
+
+	client := getEOS(t.mgm)
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+
+	// read the raw sys.acl, ListACLs drops the entries it cannot map to a username like egroups
+	fi, err := client.GetFileInfoByPath(ctx, "root", t.path)
+	if err != nil {
+		for _, s := range t.shares {
+			t.findings = append(t.findings, &auditFinding{Kind: findingUnresolvedPath, Share: s, Path: t.path, Entry: err.Error()})
+		}
+		return
+	}
+	acls, err := acl.Parse(fi.SysACL, acl.ShortTextForm)
+	if err != nil {
+		t.findings = append(t.findings, &auditFinding{Kind: findingInvalidACL, Path: t.path, Entry: fi.SysACL})
+		return
+	}
+	t.acls = acls
+
+	// entries set on the parent are inherited by the children, they do not need a share here
+	inherited := &acl.ACLs{}
+	if parent, err := client.GetFileInfoByPath(ctx, "root", path.Dir(t.path)); err == nil {
+		if acls, err := acl.Parse(parent.SysACL, acl.ShortTextForm); err == nil {
+			inherited = acls
+		}
+	}
+
+	expected := map[string]bool{}
+	for _, s := range t.shares {
+		aclType, qualifier := acl.TypeUser, uids.uid(s.ShareWith)
+		if s.ShareType == 1 {
+			aclType, qualifier = aclTypeEgroup, s.ShareWith
+		}
+		want := aclPermReadWrite
+		if s.Permissions == 1 {
+			want = aclPermReadOnly
+		}
+		expectedEntry := strings.Join([]string{aclType, qualifier, want}, ":")
+		expected[aclType+":"+qualifier] = true
+
+		if qualifier == "" {
+			// the recipient does not exist anymore, it cannot have an ACL
+			t.findings = append(t.findings, &auditFinding{Kind: findingMissingACL, Share: s, Path: t.path, Expected: fmt.Sprintf("u:<%s>:%s", s.ShareWith, want)})
+			continue
+		}
+		e := findACL(t.acls, aclType, qualifier)
+		if e == nil {
+			t.findings = append(t.findings, &auditFinding{Kind: findingMissingACL, Share: s, Path: t.path, Expected: expectedEntry})
+			continue
+		}
+		if aclAllowsWrite(e.Permissions) != (s.Permissions != 1) || !strings.Contains(e.Permissions, "r") {
+			t.findings = append(t.findings, &auditFinding{Kind: findingWrongPermission, Share: s, Path: t.path, Entry: aclString(e), Expected: expectedEntry})
+		}
+	}
+
+	for _, e := range t.acls.Entries {
+		if expected[e.Type+":"+e.Qualifier] || findACL(inherited, e.Type, e.Qualifier) != nil {
+			continue
+		}
+		// the owner and the project egroups are not set by shares
+		if e.Type == acl.TypeUser && e.Qualifier == fmt.Sprintf("%d", fi.UID) {
+			continue
+		}
+		if e.Type == aclTypeEgroup && strings.HasPrefix(e.Qualifier, "cernbox-project-") {
+			continue
+		}
+		if e.Type != acl.TypeUser && e.Type != aclTypeEgroup {
+			continue
+		}
+		entry := aclString(e)
+		if e.Type == acl.TypeUser {
+			entry = fmt.Sprintf("%s (%s)", entry, uids.name(e.Qualifier))
+		}
+		t.findings = append(t.findings, &auditFinding{Kind: findingOrphanACL, Path: t.path, Entry: entry})
+	}
+}
+
+func findACL(acls *acl.ACLs, aclType, qualifier string) *acl.Entry {
+	for _, e := range acls.Entries {
+		if e.Type == aclType && e.Qualifier == qualifier {
+			return e
+		}
+	}
+	return nil
+}
+
+// aclAllowsWrite returns true if the EOS permissions grant write, rwx+d or rw!m
+func aclAllowsWrite(perm string) bool {
+	return strings.Contains(perm, "w") && !strings.Contains(perm, "!w")
+}
+
+func aclString(e *acl.Entry) string {
+	return strings.Join([]string{e.Type, e.Qualifier, e.Permissions}, ":")
+}
+
+// fixedACLs returns the sys.acl the target should have after applying the findings.
+func (t *auditTarget) fixedACLs() *acl.ACLs {
+	fixed := &acl.ACLs{}
+	for _, e := range t.acls.Entries {
+		fixed.Entries = append(fixed.Entries, &acl.Entry{Type: e.Type, Qualifier: e.Qualifier, Permissions: e.Permissions})
+	}
+	for _, f := range t.findings {
+		switch f.Kind {
+		case findingMissingACL, findingWrongPermission:
+			if strings.Contains(f.Expected, "<") {
+				continue // unknown recipient
+			}
+			e, _ := acl.ParseEntry(f.Expected)
+			fixed.SetEntry(e.Type, e.Qualifier, e.Permissions)
+		case findingOrphanACL:
+			e, _ := acl.ParseEntry(strings.Split(f.Entry, " ")[0])
+			fixed.DeleteEntry(e.Type, e.Qualifier)
+		}
+	}
+	return fixed
+}
+
+func saveAuditScript(file string, targets []*auditTarget) {
+	fd, err := os.Create(file)
+	if err != nil {
+		er(err)
+	}
+	defer fd.Close()
+
+	fmt.Fprintf(fd, "#!/bin/sh\n# generated by cernboxcop sharing audit on %s\nset -e\n", time.Now().Format(time.RFC3339))
+	for _, t := range targets {
+		if t.acls == nil || len(t.findings) == 0 {
+			continue
+		}
+		for _, f := range t.findings {
+			fmt.Fprintf(fd, "# %s %s %s\n", f.Kind, f.Entry, f.Expected)
+		}
+		// only the target folder, the sub-folders keep their own sys.acl
+		fmt.Fprintf(fd, "EOS_MGM_URL=%s eos -r 0 0 attr set sys.acl=%s %s\n", t.mgm, t.fixedACLs().Serialize(), shellQuote(t.path))
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// uidCache maps usernames to unix uids and back using LDAP.
+type uidCache struct {
+	mu    sync.Mutex
+	lc    *ldap.Conn
+	uids  map[string]string // username -> uid
+	names map[string]string // uid -> username
+}
+
+// uid returns the uid of the username, empty if the account does not exist.
+// The lock is not held during the LDAP search, concurrent misses may search twice.
+func (c *uidCache) uid(username string) string {
+	c.mu.Lock()
+	uid, ok := c.uids[username]
+	c.mu.Unlock()
+	if ok {
+		return uid
+	}
+
+	uid = getUser(c.lc, username).UID
+	c.mu.Lock()
+	c.uids[username] = uid
+	c.mu.Unlock()
+	return uid
+}
+
+// name returns the username of the uid, the uid if it cannot be resolved.
+func (c *uidCache) name(uid string) string {
+	c.mu.Lock()
+	name, ok := c.names[uid]
+	c.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	name = getUserByUIDNumber(c.lc, uid).Account
+	if name == "" {
+		name = uid
+	}
+	c.mu.Lock()
+	c.names[uid] = name
+	c.mu.Unlock()
+	return name
+}
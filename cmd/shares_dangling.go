@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(shareDanglingCmd)
+	shareCmd.AddCommand(shareRestoreCmd)
+
+	shareDanglingCmd.Flags().StringP("owner", "o", "", "only scan the shares of this owner account")
+	shareDanglingCmd.Flags().IntP("concurrency", "", 100, "use up to <n> concurrent connections to resolve paths")
+	shareDanglingCmd.Flags().IntP("attempts", "", 0, "try a path lookup up to <n> times while the MGM is failing (default path_resolver_max_attempts)")
+	shareDanglingCmd.Flags().BoolP("status", "", false, "shows the status when it is resolving EOS paths")
+	shareDanglingCmd.Flags().BoolP("delete", "", false, "delete the dangling shares, by default only a dry-run is done")
+	shareDanglingCmd.Flags().StringP("backup", "", "", "file to backup the deleted rows to (default sharing-dangling-<timestamp>.json)")
+	shareDanglingCmd.Flags().BoolP("yes", "y", false, "deletes the dangling shares without confirmation")
+	shareDanglingCmd.Flags().Float64P("max-percent", "", 10, "refuse to delete when more than <n>% of the scanned shares are dangling")
+}
+
+// Reasons for a share to be dangling.
+const (
+	danglingDeleted = "deleted" // the inode does not exist anymore
+	danglingRecycle = "recycle" // the file is in the recycle bin
+	danglingVersion = "version" // the file is inside a version folder
+	danglingError   = "error"   // the lookup failed, never deleted
+	danglingNone    = ""
+)
+
+// shareRow is a full oc_share row keyed by column, NULL values are nil.
+type shareRow map[string]*string
+
+var shareDanglingCmd = &cobra.Command{
+	Use:   "dangling",
+	Short: "Finds and removes the shares pointing to files that do not exist anymore",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		attempts, _ := cmd.Flags().GetInt("attempts")
+		status, _ := cmd.Flags().GetBool("status")
+		del, _ := cmd.Flags().GetBool("delete")
+		backup, _ := cmd.Flags().GetString("backup")
+		yes, _ := cmd.Flags().GetBool("yes")
+		maxPercent, _ := cmd.Flags().GetFloat64("max-percent")
+
+		owner = strings.TrimSpace(owner)
+		if concurrency < 1 || attempts < 0 {
+			exit(cmd)
+		}
+
+		var shares []*dbShare
+		var err error
+		if owner != "" {
+			shares, err = getSharesByOwner(owner)
+		} else {
+			shares, err = getAllShares()
+		}
+		if err != nil {
+			er(err)
+		}
+
+		// never trust the path cache to decide what to delete
+		opts := getPathResolverOptions()
+		opts.CacheDB = ""
+		if attempts > 0 {
+			opts.MaxAttempts = attempts
+		}
+		resolver := newPathResolver(opts)
+		defer resolver.close()
+		dangling := findDanglingShares(shares, resolver, concurrency, status)
+
+		cols := []string{"ID", "FILEID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "REASON", "PATH"}
+		rows := [][]string{}
+		ids := []int{}
+		for _, d := range dangling {
+			s := d.share
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.FileID(), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), d.reason, d.path})
+			if d.reason != danglingError {
+				ids = append(ids, s.ID)
+			}
+		}
+		pretty(cols, rows)
+
+		if !del {
+			fmt.Fprintf(os.Stderr, "%d dangling shares found, dry-run: use --delete to remove them\n", len(ids))
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+
+		// so many dangling shares is more likely an MGM answering wrong than real deletions
+		if percent := float64(len(ids)) * 100 / float64(len(shares)); percent > maxPercent {
+			fmt.Fprintf(os.Stderr, "Error: %d of %d shares (%.1f%%) are dangling, above --max-percent %g, refusing to delete\n", len(ids), len(shares), percent, maxPercent)
+			os.Exit(1)
+		}
+
+		if !yes {
+			msg := fmt.Sprintf("Are you sure to delete %d dangling shares?\n", len(ids))
+			if !askForConfirmation(msg) {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		if backup == "" {
+			backup = fmt.Sprintf("sharing-dangling-%s.json", time.Now().Format("20060102-150405"))
+		}
+		deleteShares(ids, backup)
+		fmt.Fprintf(os.Stderr, "%d shares deleted, backup saved to %s\n", len(ids), backup)
+	},
+}
+
+var shareRestoreCmd = &cobra.Command{
+	Use:   "restore <backup.json>",
+	Short: "Restores the shares from a backup written when deleting shares",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			er(err)
+		}
+		rows := []shareRow{}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			er(err)
+		}
+
+		restoreShares(rows)
+		fmt.Fprintf(os.Stderr, "%d shares restored\n", len(rows))
+	},
+}
+
+type danglingShare struct {
+	share  *dbShare
+	path   string
+	reason string
+}
+
+func findDanglingShares(shares []*dbShare, resolver *pathResolver, concurrency int, status bool) []*danglingShare {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	limit := make(chan struct{}, concurrency) // used to limit the number of concurrent goroutines
+
+	spin := NewDeterminatedSpinStatus("Resolving EOS paths", len(shares))
+	if status {
+		spin.Start()
+	}
+
+	dangling := []*danglingShare{}
+	for _, share := range shares {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(s *dbShare) {
+			defer func() { <-limit; wg.Done() }()
+			p, err := resolver.resolve(getCtx(), s.Prefix, s.ItemSource, true)
+			reason := danglingReason(p, err)
+			if err != nil {
+				p = err.Error()
+			}
+			if reason == danglingDeleted {
+				p, reason = confirmDeleted(s)
+			}
+			if status {
+				spin.Update(1)
+			}
+			if reason == danglingNone {
+				return
+			}
+			mu.Lock()
+			dangling = append(dangling, &danglingShare{share: s, path: p, reason: reason})
+			mu.Unlock()
+		}(share)
+	}
+	wg.Wait()
+	if status {
+		spin.Done()
+	}
+
+	sort.Slice(dangling, func(i, j int) bool {
+		return dangling[i].share.ID < dangling[j].share.ID
+	})
+	return dangling
+}
+
+// danglingReason classifies the result of a path lookup.
+func danglingReason(p string, err error) string {
+	if err == errInodeNotFound {
+		return danglingDeleted
+	}
+	if err != nil {
+		return danglingError
+	}
+	// /eos/user/proc/recycle/uid:1234/2020/11/09/0/...
+	if strings.Contains(p, "/proc/recycle/") {
+		return danglingRecycle
+	}
+	// /eos/user/g/gonzalhu/.sys.v#.notes.txt/1604911200.0004ab3c
+	if strings.Contains(p, "/.sys.v#.") {
+		return danglingVersion
+	}
+	return danglingNone
+}
+
+// confirmDeleted looks the inode up a second time on the owning MGM, an empty
+// fileinfo answer alone is not enough to delete a share.
+func confirmDeleted(s *dbShare) (string, string) {
+	inode, err := strconv.ParseUint(s.ItemSource, 10, 64)
+	if err != nil {
+		return err.Error(), danglingError
+	}
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+
+	fi, err := getEOS(getTopology().mgmForInstance(s.Prefix)).GetFileInfoByInode(ctx, "root", inode)
+	if err == nil {
+		return fi.File, danglingReason(fi.File, nil)
+	}
+	if _, ok := err.(errtypes.IsNotFound); ok {
+		return errInodeNotFound.Error(), danglingDeleted
+	}
+	return err.Error(), danglingError
+}
+
+// deleteShares removes the shares in one transaction after saving the full rows to the backup file.
+func deleteShares(ids []int, backup string) {
+	db := getDB()
+	tx, err := db.Begin()
+	if err != nil {
+		er(err)
+	}
+
+	rows, err := getShareRows(tx, ids)
+	if err != nil {
+		tx.Rollback()
+		er(err)
+	}
+
+	data, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		tx.Rollback()
+		er(err)
+	}
+	if err := ioutil.WriteFile(backup, data, 0600); err != nil {
+		tx.Rollback()
+		er(err)
+	}
+
+	for _, chunk := range chunkIDs(ids, 500) {
+		query := fmt.Sprintf("delete from oc_share where id in (%s)", placeholders(len(chunk)))
+		if _, err := tx.Exec(query, chunk...); err != nil {
+			tx.Rollback()
+			er(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		er(err)
+	}
+}
+
+// getShareRows returns the full rows of the shares with all the oc_share columns.
+func getShareRows(tx *sql.Tx, ids []int) ([]shareRow, error) {
+	shareRows := []shareRow{}
+	for _, chunk := range chunkIDs(ids, 500) {
+		query := fmt.Sprintf("select * from oc_share where id in (%s)", placeholders(len(chunk)))
+		rows, err := tx.Query(query, chunk...)
+		if err != nil {
+			return nil, err
+		}
+
+		cols, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for rows.Next() {
+			values := make([]sql.NullString, len(cols))
+			dest := make([]interface{}, len(cols))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			row := shareRow{}
+			for i, c := range cols {
+				if values[i].Valid {
+					v := values[i].String
+					row[c] = &v
+				} else {
+					row[c] = nil
+				}
+			}
+			shareRows = append(shareRows, row)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return shareRows, nil
+}
+
+// restoreShares inserts the backup rows back in one transaction.
+func restoreShares(rows []shareRow) {
+	db := getDB()
+	tx, err := db.Begin()
+	if err != nil {
+		er(err)
+	}
+
+	known, err := getShareColumns(tx)
+	if err != nil {
+		tx.Rollback()
+		er(err)
+	}
+
+	for _, row := range rows {
+		cols := make([]string, 0, len(row))
+		for c := range row {
+			// the columns come from the backup file, only the ones of oc_share are accepted
+			if !isInList(known, c) {
+				tx.Rollback()
+				er(fmt.Sprintf("unknown oc_share column %q in the backup", c))
+			}
+			cols = append(cols, c)
+		}
+		sort.Strings(cols)
+
+		args := make([]interface{}, 0, len(cols))
+		for _, c := range cols {
+			if row[c] == nil {
+				args = append(args, nil)
+			} else {
+				args = append(args, *row[c])
+			}
+		}
+
+		query := fmt.Sprintf("insert into oc_share (`%s`) values (%s)", strings.Join(cols, "`, `"), placeholders(len(cols)))
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			er(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		er(err)
+	}
+}
+
+// getShareColumns returns the columns of the oc_share table.
+func getShareColumns(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query("select * from oc_share limit 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func chunkIDs(ids []int, size int) [][]interface{} {
+	chunks := [][]interface{}{}
+	for i := 0; i < len(ids); i += size {
+		chunk := []interface{}{}
+		for j := i; j < len(ids) && j < i+size; j++ {
+			chunk = append(chunk, ids[j])
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(shareExpireCmd)
+	shareCmd.AddCommand(shareEnforceExpiryCmd)
+
+	shareExpireCmd.Flags().StringP("owner", "o", "", "set the expiration of all the public links of this owner account")
+	shareExpireCmd.Flags().StringP("at", "", "", "expiration day (YYYY-MM-DD)")
+	shareExpireCmd.Flags().IntP("in", "", 0, "expire in <n> days")
+	shareExpireCmd.Flags().BoolP("clear", "", false, "remove the expiration")
+	shareExpireCmd.Flags().BoolP("yes", "y", false, "sets the expirations without confirmation")
+
+	shareEnforceExpiryCmd.Flags().StringP("action", "", "delete", "delete: remove the expired shares, downgrade: read-write shares become read-only without expiration and read-only ones are removed")
+	shareEnforceExpiryCmd.Flags().BoolP("dry-run", "", false, "only show what would be done")
+	shareEnforceExpiryCmd.Flags().BoolP("no-email", "", false, "do not email the owners")
+	shareEnforceExpiryCmd.Flags().StringP("backup", "", "", "file to backup the deleted rows to (default sharing-expired-<timestamp>.json)")
+}
+
+const (
+	expiryDelete    = "delete"
+	expiryDowngrade = "downgrade"
+)
+
+var shareExpireCmd = &cobra.Command{
+	Use:   "expire <share-id> | --owner <account> (--at <YYYY-MM-DD> | --in <days> | --clear)",
+	Short: "Sets or clears the expiration of a share or of all the public links of an owner",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		at, _ := cmd.Flags().GetString("at")
+		in, _ := cmd.Flags().GetInt("in")
+		clearExpiration, _ := cmd.Flags().GetBool("clear")
+		yes, _ := cmd.Flags().GetBool("yes")
+		owner = strings.TrimSpace(owner)
+
+		var expiration interface{} // nil clears the expiration
+		switch {
+		case clearExpiration && at == "" && in == 0:
+		case !clearExpiration && at != "" && in == 0:
+			t, err := time.ParseInLocation(dayLayout, at, time.Local)
+			if err != nil {
+				er(err)
+			}
+			expiration = t.Format(shareExpirationLayout)
+		case !clearExpiration && at == "" && in > 0:
+			t := time.Now().Local().AddDate(0, 0, in)
+			expiration = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local).Format(shareExpirationLayout)
+		default:
+			exit(cmd)
+		}
+
+		var shares []*dbShare
+		var err error
+		if len(args) == 1 && owner == "" {
+			shares, err = getSharesByID(strings.TrimSpace(args[0]))
+		} else if len(args) == 0 && owner != "" {
+			shares, err = getPublicLinks(owner)
+		} else {
+			exit(cmd)
+		}
+		if err != nil {
+			er(err)
+		}
+		if len(shares) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no shares found\n")
+			os.Exit(1)
+		}
+
+		newExpiration := "-"
+		if expiration != nil {
+			newExpiration = expiration.(string)
+		}
+		cols := []string{"ID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "EXPIRATION", "NEW_EXPIRATION"}
+		rows := [][]string{}
+		ids := []int{}
+		for _, s := range shares {
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), s.HumanExpiration(), newExpiration})
+			ids = append(ids, s.ID)
+		}
+		pretty(cols, rows)
+
+		checkAuditLog()
+
+		if len(shares) > 1 && !yes {
+			msg := fmt.Sprintf("Are you sure to set the expiration of %d shares?\n", len(shares))
+			if !askForConfirmation(msg) {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		db := getDB()
+		for _, chunk := range chunkIDs(ids, 500) {
+			query := fmt.Sprintf("update oc_share set expiration=? where id in (%s)", placeholders(len(chunk)))
+			if _, err := db.Exec(query, append([]interface{}{expiration}, chunk...)...); err != nil {
+				er(err)
+			}
+		}
+
+		strIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strIDs = append(strIDs, fmt.Sprintf("%d", id))
+		}
+		auditLog("sharing-expire", map[string]string{
+			"ids":        strings.Join(strIDs, ","),
+			"owner":      owner,
+			"expiration": newExpiration,
+		})
+	},
+}
+
+type expiredShare struct {
+	share  *dbShare
+	path   string
+	action string
+}
+
+var shareEnforceExpiryCmd = &cobra.Command{
+	Use:   "enforce-expiry",
+	Short: "Deletes or downgrades the expired shares and emails their owners, meant to run from cron",
+	Run: func(cmd *cobra.Command, args []string) {
+		action, _ := cmd.Flags().GetString("action")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		noEmail, _ := cmd.Flags().GetBool("no-email")
+		backup, _ := cmd.Flags().GetString("backup")
+
+		if action != expiryDelete && action != expiryDowngrade {
+			exit(cmd)
+		}
+
+		now := time.Now()
+		shares, err := getShares(shareSelect+" where expiration is not null and expiration < ?", []interface{}{now.Format(shareExpirationLayout)})
+		if err != nil {
+			er(err)
+		}
+
+		expired := []*expiredShare{}
+		for _, s := range shares {
+			if !s.Expired(now) {
+				continue
+			}
+			e := &expiredShare{share: s, path: s.GetPath(), action: expiryDelete}
+			if action == expiryDowngrade && s.Permissions != 1 {
+				e.action = expiryDowngrade
+			}
+			expired = append(expired, e)
+		}
+
+		cols := []string{"ID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "EXPIRATION", "PATH", "ACTION"}
+		rows := [][]string{}
+		for _, e := range expired {
+			s := e.share
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), s.HumanExpiration(), e.path, e.action})
+		}
+		pretty(cols, rows)
+
+		if dryRun || len(expired) == 0 {
+			return
+		}
+
+		if backup == "" {
+			backup = fmt.Sprintf("sharing-expired-%s.json", now.Format("20060102-150405"))
+		}
+		enforceExpiry(expired, backup, now)
+
+		if !noEmail {
+			notifyExpiredOwners(expired)
+		}
+	},
+}
+
+func enforceExpiry(expired []*expiredShare, backup string, now time.Time) {
+	ids := []int{}
+	for _, e := range expired {
+		if e.action == expiryDelete {
+			ids = append(ids, e.share.ID)
+		}
+	}
+	if len(ids) > 0 {
+		deleteShares(ids, backup)
+		fmt.Fprintf(os.Stderr, "%d shares deleted, backup saved to %s\n", len(ids), backup)
+	}
+
+	db := getDB()
+	tx, err := db.Begin()
+	if err != nil {
+		er(err)
+	}
+	for _, e := range expired {
+		if e.action != expiryDowngrade {
+			continue
+		}
+		if _, err := tx.Exec("update oc_share set permissions=1, expiration=NULL where id=?", e.share.ID); err != nil {
+			tx.Rollback()
+			er(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		er(err)
+	}
+
+	// the database is the source of truth, ACL errors are reported for sharing audit to fix
+	for _, e := range expired {
+		if err := revokeShareACL(e.share, e.path, e.action == expiryDowngrade, now); err != nil {
+			fmt.Fprintf(os.Stderr, "error updating ACL of share %d on %s: %+v\n", e.share.ID, e.path, err)
+		}
+	}
+}
+
+// otherActiveShare returns another share of the same recipient on the same file
+// that has not expired, the read-write one if there are several, nil if none.
+func otherActiveShare(s *dbShare, now time.Time) (*dbShare, error) {
+	shares, err := getSharesByInodes([]string{s.ItemSource})
+	if err != nil {
+		return nil, err
+	}
+	var other *dbShare
+	for _, o := range shares {
+		if o.ID == s.ID || o.ShareType != s.ShareType || o.ShareWith != s.ShareWith || o.FileID() != s.FileID() || o.Expired(now) {
+			continue
+		}
+		if other == nil || o.Permissions != sharePermReadOnly {
+			other = o
+		}
+	}
+	return other, nil
+}
+
+// revokeShareACL removes the ACL of the recipient of a deleted share, or makes it
+// read-only for a downgraded one, unless another active share of the recipient
+// on the same file still needs it.
+func revokeShareACL(s *dbShare, path string, downgrade bool, now time.Time) error {
+	other, err := otherActiveShare(s, now)
+	if err != nil {
+		return err
+	}
+	switch {
+	case other != nil && other.Permissions != sharePermReadOnly:
+		// the other share keeps the read-write ACL
+		return nil
+	case other != nil || downgrade:
+		return setShareACL(s, path, aclPermReadOnly)
+	}
+	return removeShareACL(s, path)
+}
+
+// shareACLEntry returns the sys.acl type and qualifier of a user or egroup share.
+// Public links do not have ACLs.
+func shareACLEntry(s *dbShare) (string, string, bool) {
+	switch s.ShareType {
+	case 0:
+		return acl.TypeUser, s.ShareWith, true
+	case 1:
+		return aclTypeEgroup, s.ShareWith, true
+	}
+	return "", "", false
+}
+
+// setShareACL grants the recipient of the share the permissions on the path.
+func setShareACL(s *dbShare, path, perm string) error {
+	aclType, qualifier, ok := shareACLEntry(s)
+	if !ok {
+		return nil
+	}
+	if path == "-" {
+		return fmt.Errorf("path not found")
+	}
+	client := getEOS(getTopology().mgmForInstance(s.Prefix))
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	return client.AddACL(ctx, "root", path, &acl.Entry{Type: aclType, Qualifier: qualifier, Permissions: perm})
+}
+
+// removeShareACL removes the recipient of the share from the ACL of the path.
+func removeShareACL(s *dbShare, path string) error {
+	aclType, qualifier, ok := shareACLEntry(s)
+	if !ok || path == "-" {
+		// nothing to remove on a file that does not exist anymore
+		return nil
+	}
+	client := getEOS(getTopology().mgmForInstance(s.Prefix))
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+	return client.RemoveACL(ctx, "root", path, aclType, qualifier)
+}
+
+func notifyExpiredOwners(expired []*expiredShare) {
+	byOwner := map[string][]*expiredShare{}
+	owners := []string{}
+	for _, e := range expired {
+		if _, ok := byOwner[e.share.UIDOwner]; !ok {
+			owners = append(owners, e.share.UIDOwner)
+		}
+		byOwner[e.share.UIDOwner] = append(byOwner[e.share.UIDOwner], e)
+	}
+
+	lc := getLDAP()
+	defer lc.Close()
+	for _, owner := range owners {
+		ui := getUser(lc, owner)
+		if ui.Mail == "" {
+			fmt.Fprintf(os.Stderr, "no email found for %s\n", owner)
+			continue
+		}
+
+		body := fmt.Sprintf("Dear %s,\r\n\r\nThe following CERNBox shares you own have expired:\r\n\r\n", ui.Name)
+		for _, e := range byOwner[owner] {
+			s := e.share
+			what := "removed"
+			if e.action == expiryDowngrade {
+				what = "changed to read-only"
+			}
+			with := s.HumanShareWith()
+			if s.ShareType == 3 {
+				with = s.PublicLink()
+			}
+			body += fmt.Sprintf("* %s shared with %s, expired on %s: %s\r\n", e.path, with, s.Expiration, what)
+		}
+		body += "\r\nYou can share the files again from CERNBox if they are still needed.\r\n\r\nThe CERNBox team\r\n"
+
+		msg := "From: " + getEmailSender() + "\r\n" +
+			"To: " + ui.Mail + "\r\n" +
+			"Subject: CERNBox: " + strconv.Itoa(len(byOwner[owner])) + " of your shares expired\r\n" +
+			"\r\n" +
+			body
+		if err := sendEmailTo([]string{ui.Mail}, msg); err != nil {
+			fmt.Fprintf(os.Stderr, "error sending email to %s: %+v\n", ui.Mail, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(shareCreateCmd)
+	shareCmd.AddCommand(shareDeleteCmd)
+
+	shareCreateCmd.Flags().StringP("owner", "o", "", "owner account of the share")
+	shareCreateCmd.Flags().StringP("path", "p", "", "eos path to share")
+	shareCreateCmd.Flags().StringP("with", "", "", "share with this account")
+	shareCreateCmd.Flags().StringP("egroup", "", "", "share with this egroup")
+	shareCreateCmd.Flags().BoolP("public", "", false, "create a public link")
+	shareCreateCmd.Flags().StringP("permission", "", "read-only", "read-only or read-write")
+	shareCreateCmd.Flags().BoolP("dry-run", "", false, "only show the share that would be created")
+
+	shareDeleteCmd.Flags().BoolP("dry-run", "", false, "only show the share that would be deleted")
+	shareDeleteCmd.Flags().StringP("backup", "", "", "file to backup the deleted row to (default sharing-delete-<id>-<timestamp>.json)")
+	shareDeleteCmd.Flags().BoolP("yes", "y", false, "deletes the share without confirmation")
+	shareDeleteCmd.Flags().BoolP("force", "", false, "delete the share even if its path cannot be resolved, the ACL is then left as is")
+}
+
+// Share permissions as set by the web app.
+const (
+	sharePermReadOnly  = 1
+	sharePermReadWrite = 15
+)
+
+var shareCreateCmd = &cobra.Command{
+	Use:   "create --owner <account> --path <eos-path> (--with <account> | --egroup <egroup> | --public) [--permission read-only|read-write]\nExample: cernboxcop sharing create --owner gonzalhu --path /eos/user/g/gonzalhu/docs --egroup cernbox-admins",
+	Short: "Creates a share like the web app does, including the EOS ACL",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		p, _ := cmd.Flags().GetString("path")
+		with, _ := cmd.Flags().GetString("with")
+		egroup, _ := cmd.Flags().GetString("egroup")
+		public, _ := cmd.Flags().GetBool("public")
+		permission, _ := cmd.Flags().GetString("permission")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		owner, p = strings.TrimSpace(owner), strings.TrimSpace(p)
+		with, egroup = strings.TrimSpace(with), strings.TrimSpace(egroup)
+		if owner == "" || p == "" {
+			exit(cmd)
+		}
+
+		s := &dbShare{UIDOwner: owner, STime: int(time.Now().Unix())}
+		switch {
+		case with != "" && egroup == "" && !public:
+			s.ShareType, s.ShareWith = 0, with
+		case with == "" && egroup != "" && !public:
+			s.ShareType, s.ShareWith = 1, egroup
+		case with == "" && egroup == "" && public:
+			s.ShareType, s.Token = 3, newShareToken()
+		default:
+			exit(cmd)
+		}
+
+		switch permission {
+		case "read-only":
+			s.Permissions = sharePermReadOnly
+		case "read-write":
+			s.Permissions = sharePermReadWrite
+		default:
+			exit(cmd)
+		}
+
+		mgm, err := getTopology().mgmForPath(p)
+		if err != nil {
+			er(err)
+		}
+		fi, err := getEOS(mgm).GetFileInfoByPath(getCtx(), "root", p)
+		if err != nil {
+			er(err)
+		}
+		p = path.Clean(fi.File)
+		s.Prefix, err = getTopology().sharePrefixForPath(p)
+		if err != nil {
+			er(err)
+		}
+		s.ItemSource = fmt.Sprintf("%d", fi.Inode)
+
+		validateShareRecipient(s)
+		existing, err := getSharesByInodes([]string{s.ItemSource})
+		if err != nil {
+			er(err)
+		}
+		for _, e := range existing {
+			if s.ShareType != 3 && e.ShareType == s.ShareType && e.ShareWith == s.ShareWith && getTopology().mgmForInstance(e.Prefix) == mgm {
+				fmt.Fprintf(os.Stderr, "Error: the path is already shared with %q in share %d\n", s.ShareWith, e.ID)
+				os.Exit(1)
+			}
+		}
+
+		cols := []string{"FILEID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "PATH"}
+		rows := [][]string{{s.FileID(), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), p}}
+		pretty(cols, rows)
+
+		if dryRun {
+			return
+		}
+		checkAuditLog()
+
+		itemType := "file"
+		if fi.IsDir {
+			itemType = "folder"
+		}
+
+		db := getDB()
+		tx, err := db.Begin()
+		if err != nil {
+			er(err)
+		}
+		if err := insertShare(tx, s, itemType, "/"+path.Base(p)); err != nil {
+			tx.Rollback()
+			er(err)
+		}
+		// the share is only committed once the recipient can access the files
+		perm := aclPermReadOnly
+		if s.Permissions != sharePermReadOnly {
+			perm = aclPermReadWrite
+		}
+		if err := setShareACL(s, p, perm); err != nil {
+			tx.Rollback()
+			er(err)
+		}
+		if err := tx.Commit(); err != nil {
+			removeShareACL(s, p)
+			er(err)
+		}
+
+		auditLog("sharing-create", map[string]string{
+			"id":         fmt.Sprintf("%d", s.ID),
+			"owner":      s.UIDOwner,
+			"type":       s.HumanType(),
+			"share_with": s.ShareWith,
+			"permission": s.HumanPerm(),
+			"fileid":     s.FileID(),
+			"path":       p,
+		})
+		fmt.Printf("share %d created\n", s.ID)
+	},
+}
+
+var shareDeleteCmd = &cobra.Command{
+	Use:   "delete <share-id>",
+	Short: "Deletes a share and removes the EOS ACL of the recipient",
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		backup, _ := cmd.Flags().GetString("backup")
+		yes, _ := cmd.Flags().GetBool("yes")
+		force, _ := cmd.Flags().GetBool("force")
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		shares, err := getSharesByID(strings.TrimSpace(args[0]))
+		if err != nil {
+			er(err)
+		}
+		if len(shares) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: share does not exist\n")
+			os.Exit(1)
+		}
+		s := shares[0]
+		p := s.GetPath()
+		if _, _, hasACL := shareACLEntry(s); hasACL && p == "-" && !force {
+			fmt.Fprintf(os.Stderr, "Error: the path of share %d cannot be resolved so its ACL cannot be removed, use --force to delete it anyway\n", s.ID)
+			os.Exit(1)
+		}
+
+		cols := []string{"ID", "FILEID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "URL", "PATH"}
+		rows := [][]string{{fmt.Sprintf("%d", s.ID), s.FileID(), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), s.PublicLink(), p}}
+		pretty(cols, rows)
+
+		if dryRun {
+			return
+		}
+		checkAuditLog()
+
+		if !yes {
+			if !askForConfirmation("Are you sure to delete the share?\n") {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		if backup == "" {
+			backup = fmt.Sprintf("sharing-delete-%d-%s.json", s.ID, time.Now().Format("20060102-150405"))
+		}
+		deleteShares([]int{s.ID}, backup)
+
+		// keep the ACL if the recipient still has another share on the same file
+		if err := revokeShareACL(s, p, false, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "error removing ACL of share %d on %s: %+v\n", s.ID, p, err)
+		}
+
+		auditLog("sharing-delete", map[string]string{
+			"id":         fmt.Sprintf("%d", s.ID),
+			"owner":      s.UIDOwner,
+			"type":       s.HumanType(),
+			"share_with": s.ShareWith,
+			"permission": s.HumanPerm(),
+			"fileid":     s.FileID(),
+			"path":       p,
+			"backup":     backup,
+		})
+		fmt.Printf("share %d deleted, backup saved to %s\n", s.ID, backup)
+	},
+}
+
+// validateShareRecipient exits if the recipient of the share does not exist.
+func validateShareRecipient(s *dbShare) {
+	lc := getLDAP()
+	defer lc.Close()
+
+	if getUser(lc, s.UIDOwner).Account == "" {
+		er(fmt.Sprintf("owner %q does not exist", s.UIDOwner))
+	}
+	switch s.ShareType {
+	case 0:
+		if getUser(lc, s.ShareWith).Account == "" {
+			er(fmt.Sprintf("account %q does not exist", s.ShareWith))
+		}
+	case 1:
+		if _, exists := getEgroupMembers(lc, s.ShareWith); !exists {
+			er(fmt.Sprintf("egroup %q does not exist", s.ShareWith))
+		}
+	}
+}
+
+// insertShare inserts the share with the columns the web app sets and fills the share id.
+func insertShare(tx *sql.Tx, s *dbShare, itemType, fileTarget string) error {
+	query := "insert into oc_share set share_type=?, uid_owner=?, uid_initiator=?, item_type=?, fileid_prefix=?, item_source=?, file_source=?, file_target=?, permissions=?, stime=?"
+	args := []interface{}{s.ShareType, s.UIDOwner, s.UIDOwner, itemType, s.Prefix, s.ItemSource, s.ItemSource, fileTarget, s.Permissions, s.STime}
+	if s.ShareType == 3 {
+		query += ", token=?"
+		args = append(args, s.Token)
+	} else {
+		query += ", share_with=?"
+		args = append(args, s.ShareWith)
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = int(id)
+	return nil
+}
+
+const shareTokenChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newShareToken returns a random public link token of 15 characters like the web app.
+func newShareToken() string {
+	token := make([]byte, 15)
+	for i := range token {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(shareTokenChars))))
+		if err != nil {
+			er(err)
+		}
+		token[i] = shareTokenChars[n.Int64()]
+	}
+	return string(token)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(sharePublicLinksCmd)
+
+	sharePublicLinksCmd.Flags().StringP("owner", "o", "", "filter by owner account")
+	sharePublicLinksCmd.Flags().IntP("older-than", "", 365, "flag read-write links older than <n> days")
+	sharePublicLinksCmd.Flags().BoolP("flagged", "", false, "only show the flagged links")
+	sharePublicLinksCmd.Flags().IntP("concurrency", "", 100, "use up to <n> concurrent connections to resolve paths")
+	sharePublicLinksCmd.Flags().BoolP("status", "", false, "shows the status when it is resolving EOS paths")
+}
+
+// Public link risk flags.
+const (
+	flagOldReadWrite  = "old-read-write"
+	flagOwnerDeparted = "owner-departed"
+	flagOwnerDisabled = "owner-disabled"
+	flagProjectSpace  = "project-space"
+)
+
+var sharePublicLinksCmd = &cobra.Command{
+	Use:   "public-links",
+	Short: "Lists the public links with their risk flags for security reviews",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		olderThan, _ := cmd.Flags().GetInt("older-than")
+		flagged, _ := cmd.Flags().GetBool("flagged")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		status, _ := cmd.Flags().GetBool("status")
+
+		owner = strings.TrimSpace(owner)
+		if concurrency < 1 {
+			exit(cmd)
+		}
+
+		links, err := getPublicLinks(owner)
+		if err != nil {
+			er(err)
+		}
+
+		paths := resolvePaths(links, concurrency, status)
+		owners := getOwnerAccounts(links)
+		projects := getProjects(All{})
+
+		now := time.Now()
+		cols := []string{"ID", "OWNER", "ACCOUNT_TYPE", "ACCOUNT_STATUS", "PERMISSION", "CREATED", "AGE_DAYS", "URL", "PATH", "FLAGS"}
+		rows := [][]string{}
+		for _, s := range links {
+			ui := owners[s.UIDOwner]
+			created := time.Unix(int64(s.STime), 0)
+			age := int(now.Sub(created).Hours() / 24)
+
+			accountStatus := "active"
+			flags := []string{}
+			if s.Permissions != 1 && age > olderThan {
+				flags = append(flags, flagOldReadWrite)
+			}
+			if ui.Account == "" {
+				accountStatus = "departed"
+				flags = append(flags, flagOwnerDeparted)
+			} else if ui.Disabled {
+				accountStatus = "disabled"
+				flags = append(flags, flagOwnerDisabled)
+			}
+			if strings.Contains(s.Prefix, "project") || projectFromPath(paths[s.ID], projects) != nil {
+				flags = append(flags, flagProjectSpace)
+			}
+
+			if flagged && len(flags) == 0 {
+				continue
+			}
+			humanFlags := "-"
+			if len(flags) > 0 {
+				humanFlags = strings.Join(flags, ",")
+			}
+
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.UIDOwner, ui.AccountType, accountStatus, s.HumanPerm(), created.Format(dayLayout), fmt.Sprintf("%d", age), s.PublicLink(), paths[s.ID], humanFlags})
+		}
+		pretty(cols, rows)
+	},
+}
+
+func getPublicLinks(owner string) (shares []*dbShare, err error) {
+	query := shareSelect + " where share_type=3"
+	args := []interface{}{}
+	if owner != "" {
+		query += " and uid_owner=?"
+		args = append(args, owner)
+	}
+
+	return getShares(query, args)
+}
+
+// resolvePaths returns the EOS path of every share by share id.
+func resolvePaths(shares []*dbShare, concurrency int, status bool) map[int]string {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	limit := make(chan struct{}, concurrency) // used to limit the number of concurrent goroutines
+
+	spin := NewDeterminatedSpinStatus("Resolving EOS paths", len(shares))
+	if status {
+		spin.Start()
+	}
+
+	paths := map[int]string{}
+	for _, share := range shares {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(s *dbShare) {
+			defer func() { <-limit; wg.Done() }()
+			p := s.GetPath()
+			mu.Lock()
+			paths[s.ID] = p
+			mu.Unlock()
+			if status {
+				spin.Update(1)
+			}
+		}(share)
+	}
+	wg.Wait()
+	if status {
+		spin.Done()
+	}
+	return paths
+}
+
+// getOwnerAccounts looks up the owners of the shares in LDAP.
+// Owners that left CERN get an empty userInfo.
+func getOwnerAccounts(shares []*dbShare) map[string]*userInfo {
+	lc := getLDAP()
+	defer lc.Close()
+
+	owners := map[string]*userInfo{}
+	for _, s := range shares {
+		if _, ok := owners[s.UIDOwner]; !ok {
+			owners[s.UIDOwner] = getUser(lc, s.UIDOwner)
+		}
+	}
+	return owners
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/spf13/cobra"
+	"gopkg.in/ldap.v3"
+)
+
+func init() {
+	shareCmd.AddCommand(shareReachabilityCmd)
+
+	shareReachabilityCmd.Flags().StringP("owner", "o", "", "check all the shares of this owner account")
+	shareReachabilityCmd.Flags().StringP("user", "u", "", "check that this account can reach the shares, the colleague opening the ticket")
+}
+
+var shareReachabilityCmd = &cobra.Command{
+	Use:   "reachability <share-id> | --owner <account>",
+	Short: "Checks that the recipients of user and egroup shares can reach the shared path",
+	Run: func(cmd *cobra.Command, args []string) {
+		owner, _ := cmd.Flags().GetString("owner")
+		user, _ := cmd.Flags().GetString("user")
+		owner = strings.TrimSpace(owner)
+		user = strings.TrimSpace(user)
+
+		var shares []*dbShare
+		var err error
+		if len(args) == 1 && owner == "" {
+			shares, err = getSharesByID(strings.TrimSpace(args[0]))
+		} else if len(args) == 0 && owner != "" {
+			shares, err = getSharesByOwner(owner)
+		} else {
+			exit(cmd)
+		}
+		if err != nil {
+			er(err)
+		}
+
+		lc := getLDAP()
+		defer lc.Close()
+
+		var userGroups []string
+		if user != "" {
+			userGroups = getUserGroups(user)
+		}
+
+		cols := []string{"ID", "OWNER", "TYPE", "SHARE_WITH", "PERMISSION", "PATH", "RECIPIENT", "ACL", "REACHABLE"}
+		rows := [][]string{}
+		for _, s := range shares {
+			if s.ShareType != 0 && s.ShareType != 1 {
+				continue
+			}
+			path := s.GetPath()
+			recipient, qualifier := checkShareRecipient(lc, s, user, userGroups)
+			grant := checkShareACL(s, path, qualifier)
+			reachable := "no"
+			if recipient == "ok" && grant == "ok" {
+				reachable = "yes"
+			}
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.UIDOwner, s.HumanType(), s.HumanShareWith(), s.HumanPerm(), path, recipient, grant, reachable})
+		}
+		pretty(cols, rows)
+	},
+}
+
+// checkShareRecipient checks the recipient of the share in LDAP and returns
+// the status and the ACL qualifier (uid or egroup) the recipient is granted by.
+// If user is set it also checks that the user is the recipient or a member of it.
+func checkShareRecipient(lc *ldap.Conn, s *dbShare, user string, userGroups []string) (string, string) {
+	if s.ShareType == 1 {
+		members, exists := getEgroupMembers(lc, s.ShareWith)
+		if !exists {
+			return "egroup does not exist", ""
+		}
+		if len(members) == 0 {
+			return "egroup is empty", s.ShareWith
+		}
+		if user != "" && !isInList(userGroups, s.ShareWith) {
+			return fmt.Sprintf("%s is not member of the egroup", user), s.ShareWith
+		}
+		return "ok", s.ShareWith
+	}
+
+	ui := getUser(lc, s.ShareWith)
+	if ui.Account == "" {
+		return "account does not exist", ""
+	}
+	if ui.Disabled {
+		return "account is disabled", ui.UID
+	}
+	if ui.UID == "" {
+		return "account has no uid", ""
+	}
+	if user != "" && user != s.ShareWith {
+		return fmt.Sprintf("shared with %s not with %s", s.ShareWith, user), ui.UID
+	}
+	return "ok", ui.UID
+}
+
+// checkShareACL checks that the sys.acl of the path grants the share to the qualifier.
+func checkShareACL(s *dbShare, path, qualifier string) string {
+	if path == "-" {
+		return "path not found"
+	}
+	if qualifier == "" {
+		return "-"
+	}
+
+	client := getEOS(getTopology().mgmForInstance(s.Prefix))
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+
+	fi, err := client.GetFileInfoByPath(ctx, "root", path)
+	if err != nil {
+		return err.Error()
+	}
+	acls, err := acl.Parse(fi.SysACL, acl.ShortTextForm)
+	if err != nil {
+		return err.Error()
+	}
+
+	aclType := acl.TypeUser
+	if s.ShareType == 1 {
+		aclType = aclTypeEgroup
+	}
+	e := findACL(acls, aclType, qualifier)
+	if e == nil {
+		return "missing " + strings.Join([]string{aclType, qualifier}, ":")
+	}
+	if !strings.Contains(e.Permissions, "r") || strings.Contains(e.Permissions, "!r") {
+		return "no read in " + aclString(e)
+	}
+	if s.Permissions != 1 && !aclAllowsWrite(e.Permissions) {
+		return "no write in " + aclString(e)
+	}
+	return "ok"
+}
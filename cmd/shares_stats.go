@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(shareStatsCmd)
+
+	shareStatsCmd.Flags().IntP("top", "n", 10, "only show the top <n> owners, recipients and egroups")
+	shareStatsCmd.Flags().BoolP("push", "", false, "push the counts to influxdb")
+}
+
+// share stats dimensions, the ones with top-N are cut to the biggest counts
+var shareStatsDimensions = []struct {
+	name string
+	top  bool
+	key  func(s *dbShare) string
+}{
+	{"type", false, func(s *dbShare) string { return s.HumanType() }},
+	{"permission", false, func(s *dbShare) string { return s.HumanPerm() }},
+	{"instance", false, func(s *dbShare) string { return getTopology().canonicalInstance(s.Prefix) }},
+	{"month", false, func(s *dbShare) string { return time.Unix(int64(s.STime), 0).Format("2006-01") }},
+	{"owner", true, func(s *dbShare) string { return s.UIDOwner }},
+	{"recipient", true, func(s *dbShare) string {
+		if s.ShareType == 3 {
+			return ""
+		}
+		return s.ShareWith
+	}},
+	{"egroup", true, func(s *dbShare) string {
+		if s.ShareType != 1 {
+			return ""
+		}
+		return s.ShareWith
+	}},
+}
+
+type shareCount struct {
+	dimension string
+	key       string
+	count     int
+}
+
+var shareStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Counts the shares by type, permission, instance, month, owner and recipient",
+	Run: func(cmd *cobra.Command, args []string) {
+		top, _ := cmd.Flags().GetInt("top")
+		push, _ := cmd.Flags().GetBool("push")
+
+		shares, err := getAllShares()
+		if err != nil {
+			er(err)
+		}
+
+		counts := countShares(shares, top)
+
+		cols := []string{"DIMENSION", "KEY", "SHARES"}
+		rows := [][]string{{"total", "-", fmt.Sprintf("%d", len(shares))}}
+		for _, c := range counts {
+			rows = append(rows, []string{c.dimension, c.key, fmt.Sprintf("%d", c.count)})
+		}
+		pretty(cols, rows)
+
+		if push {
+			now := time.Now().UnixNano()
+			lines := []string{fmt.Sprintf("shares,dimension=total,key=total value=%di %d", len(shares), now)}
+			for _, c := range counts {
+				lines = append(lines, fmt.Sprintf("shares,dimension=%s,key=%s value=%di %d", c.dimension, influxTag(c.key), c.count, now))
+			}
+			writeInflux(lines)
+		}
+	},
+}
+
+// countShares returns the counts of every dimension sorted by count, months are sorted chronologically.
+func countShares(shares []*dbShare, top int) []*shareCount {
+	counts := []*shareCount{}
+	for _, d := range shareStatsDimensions {
+		byKey := map[string]int{}
+		for _, s := range shares {
+			if k := d.key(s); k != "" {
+				byKey[k]++
+			}
+		}
+
+		dim := []*shareCount{}
+		for k, v := range byKey {
+			dim = append(dim, &shareCount{dimension: d.name, key: k, count: v})
+		}
+		sort.Slice(dim, func(i, j int) bool {
+			if d.name == "month" {
+				return dim[i].key < dim[j].key
+			}
+			if dim[i].count != dim[j].count {
+				return dim[i].count > dim[j].count
+			}
+			return dim[i].key < dim[j].key
+		})
+		if d.top && len(dim) > top {
+			dim = dim[:top]
+		}
+		counts = append(counts, dim...)
+	}
+	return counts
+}
+
+// influxTag escapes a tag value for the line protocol.
+func influxTag(v string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(v)
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	shareCmd.AddCommand(shareTransferAllCmd)
+	shareCmd.AddCommand(shareTransferUndoCmd)
+
+	shareTransferAllCmd.Flags().StringP("from", "", "", "account leaving, the current owner of the shares")
+	shareTransferAllCmd.Flags().StringP("to", "", "", "new owner of the shares")
+	shareTransferAllCmd.Flags().StringP("project", "", "", "only transfer the shares inside this project")
+	shareTransferAllCmd.Flags().StringP("rollback", "", "", "file to save the previous owners to (default sharing-transfer-<from>-<timestamp>.json)")
+	shareTransferAllCmd.Flags().BoolP("yes", "y", false, "confirms transfership of ownership without confirmation")
+	shareTransferAllCmd.Flags().BoolP("skip-unresolved", "", false, "leave the shares whose path or project cannot be resolved with the current owner")
+}
+
+// ownerChange is an entry of the rollback file of a bulk transfer.
+type ownerChange struct {
+	ID       int    `json:"id"`
+	OldOwner string `json:"old_owner"`
+	NewOwner string `json:"new_owner"`
+}
+
+type transferPlan struct {
+	share   *dbShare
+	path    string
+	project string
+	problem string // why the share cannot be transferred
+	skip    bool   // shares outside projects stay with the owner
+}
+
+var shareTransferAllCmd = &cobra.Command{
+	Use:   "transfer-all --from <account> --to <account> [--project <project>]\nExample: cernboxcop sharing transfer-all --from alice --to bob --project cernbox",
+	Short: "Transfer all the project shares of an account to a new owner",
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		projectNameOrPath, _ := cmd.Flags().GetString("project")
+		rollback, _ := cmd.Flags().GetString("rollback")
+		yes, _ := cmd.Flags().GetBool("yes")
+		skipUnresolved, _ := cmd.Flags().GetBool("skip-unresolved")
+
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		projectNameOrPath = strings.TrimSpace(projectNameOrPath)
+		if from == "" || to == "" || from == to {
+			exit(cmd)
+		}
+
+		var project *projectSpace
+		if projectNameOrPath != "" {
+			p, err := getProject(projectNameOrPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: the project provided %q does not exist\n", projectNameOrPath)
+				os.Exit(1)
+			}
+			project = p
+		}
+
+		shares, err := getSharesByOwner(from)
+		if err != nil {
+			er(err)
+		}
+
+		plans := planTransfers(shares, to, project, skipUnresolved)
+		if len(plans) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: %q has no shares to transfer\n", from)
+			os.Exit(1)
+		}
+
+		cols := []string{"ID", "FILEID", "TYPE", "SHARE_WITH", "PERMISSION", "PROJECT", "PATH", "OWNER", "NEW_OWNER", "PROBLEM"}
+		rows := [][]string{}
+		var problems int
+		for _, p := range plans {
+			s := p.share
+			problem, newOwner := "-", to
+			if p.skip {
+				newOwner = "-"
+			}
+			if p.problem != "" {
+				problem = p.problem
+			}
+			if p.problem != "" && !p.skip {
+				problems++
+			}
+			rows = append(rows, []string{fmt.Sprintf("%d", s.ID), s.FileID(), s.HumanType(), s.HumanShareWith(), s.HumanPerm(), p.project, p.path, s.UIDOwner, newOwner, problem})
+		}
+		pretty(cols, rows)
+
+		if problems > 0 {
+			fmt.Fprintf(os.Stderr, "Error: %d shares cannot be transferred to %q. Only admins can manage shares, ask the user to join the admin groups, use --project to transfer the other projects or --skip-unresolved to leave the unresolved shares.\n", problems, to)
+			os.Exit(1)
+		}
+
+		changes := []*ownerChange{}
+		for _, p := range plans {
+			if !p.skip {
+				changes = append(changes, &ownerChange{ID: p.share.ID, OldOwner: from, NewOwner: to})
+			}
+		}
+		if len(changes) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: %q has no project shares to transfer\n", from)
+			os.Exit(1)
+		}
+
+		if !yes {
+			msg := fmt.Sprintf("Are you sure to transfer ownership of %d shares from %q to %q?\n", len(changes), from, to)
+			if !askForConfirmation(msg) {
+				fmt.Fprintf(os.Stderr, "Aborted\n")
+				os.Exit(1)
+			}
+		}
+
+		if rollback == "" {
+			rollback = fmt.Sprintf("sharing-transfer-%s-%s.json", from, time.Now().Format("20060102-150405"))
+		}
+		applyOwnerChanges(changes, rollback)
+		fmt.Fprintf(os.Stderr, "%d shares transferred, rollback saved to %s\n", len(changes), rollback)
+	},
+}
+
+var shareTransferUndoCmd = &cobra.Command{
+	Use:   "transfer-undo <rollback.json>",
+	Short: "Gives back the shares of a bulk transfer to their previous owners",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			exit(cmd)
+		}
+
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			er(err)
+		}
+		changes := []*ownerChange{}
+		if err := json.Unmarshal(data, &changes); err != nil {
+			er(err)
+		}
+
+		db := getDB()
+		tx, err := db.Begin()
+		if err != nil {
+			er(err)
+		}
+		for _, c := range changes {
+			if err := updateShareOwnerTx(tx, c.ID, c.NewOwner, c.OldOwner); err != nil {
+				tx.Rollback()
+				er(err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			er(err)
+		}
+		fmt.Fprintf(os.Stderr, "%d shares given back\n", len(changes))
+	},
+}
+
+// planTransfers checks every share can be transferred to the new owner:
+// the share must point inside a project and the new owner must be an admin of it.
+// The shares that cannot be resolved are always listed, skipUnresolved leaves them with the owner.
+func planTransfers(shares []*dbShare, newOwner string, project *projectSpace, skipUnresolved bool) []*transferPlan {
+	groups := getUserGroups(newOwner)
+	projects := getProjects(All{})
+	adminGroups := map[string]string{}
+
+	plans := []*transferPlan{}
+	for _, s := range shares {
+		p := &transferPlan{share: s, path: s.GetPath(), project: "-"}
+		projectInfo := projectFromPath(p.path, projects)
+		if projectInfo != nil {
+			p.project = projectInfo.name
+		}
+
+		unresolved := p.path == "-" || p.project == "-"
+		if project != nil && p.project != project.name && !(unresolved && strings.Contains(s.Prefix, "project")) {
+			continue
+		}
+
+		switch {
+		case !strings.Contains(s.Prefix, "project"):
+			p.problem = "not inside a project, skipped"
+			p.skip = true
+		case unresolved:
+			p.problem = "path not found"
+			if p.path != "-" {
+				p.problem = "project not found"
+			}
+			if skipUnresolved {
+				p.problem += ", skipped"
+				p.skip = true
+			}
+		default:
+			adminGroup, ok := adminGroups[p.project]
+			if !ok {
+				var err error
+				adminGroup, err = getProjectAdminEgroup(projectInfo)
+				if err != nil {
+					p.problem = fmt.Sprintf("admin e-group not found: %v", err)
+					break
+				}
+				adminGroups[p.project] = adminGroup
+			}
+			if !isInList(groups, adminGroup) {
+				p.problem = fmt.Sprintf("%s is not in %s", newOwner, adminGroup)
+			}
+		}
+		plans = append(plans, p)
+	}
+	return plans
+}
+
+// projectFromPath returns the project holding the path, nil if there is none:
+// /eos/project/c/cernbox/docs and the historical /eos/project/cernbox/docs => cernbox
+func projectFromPath(p string, projects []*projectSpace) *projectSpace {
+	t := getTopology()
+	if ns, _, err := t.namespaceForPath(p); err != nil || ns != t.Project {
+		return nil
+	}
+	project, err := findProject(p, projects, t.Project.Prefix)
+	if err != nil {
+		return nil
+	}
+	// findProject falls back to the closest name, the path must be inside the project folder
+	dir := strings.ToLower(path.Join(t.Project.Prefix, project.rel))
+	if lp := strings.ToLower(path.Clean(p)); lp != dir && !strings.HasPrefix(lp, dir+"/") {
+		return nil
+	}
+	return project
+}
+
+// applyOwnerChanges updates all the owners in one transaction after saving the rollback file.
+func applyOwnerChanges(changes []*ownerChange, rollback string) {
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		er(err)
+	}
+	if err := ioutil.WriteFile(rollback, data, 0600); err != nil {
+		er(err)
+	}
+
+	db := getDB()
+	tx, err := db.Begin()
+	if err != nil {
+		er(err)
+	}
+	for _, c := range changes {
+		if err := updateShareOwnerTx(tx, c.ID, c.OldOwner, c.NewOwner); err != nil {
+			tx.Rollback()
+			os.Remove(rollback)
+			er(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		os.Remove(rollback)
+		er(err)
+	}
+}
+
+// updateShareOwnerTx changes the owner of the share, failing if the share is not owned by oldOwner anymore.
+func updateShareOwnerTx(tx *sql.Tx, shareID int, oldOwner, newOwner string) error {
+	res, err := tx.Exec("update oc_share set uid_owner=? where id=? and uid_owner=?", newOwner, shareID, oldOwner)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("share %d is not owned by %s anymore", shareID, oldOwner)
+	}
+	return nil
+}
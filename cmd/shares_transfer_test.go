@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestProjectFromPath(t *testing.T) {
+	projects := []*projectSpace{
+		{name: "cernbox", rel: "c/cernbox", owner: "cboxsvc"},
+		{name: "docs", rel: "d/docs", owner: "docsvc"},
+		{name: "atlas", rel: "atlas", owner: "atlassvc"},
+	}
+
+	tuples := map[string]string{
+		"/eos/project/c/cernbox/docs/notes.txt": "cernbox",
+		"/eos/project/c/cernbox":                "cernbox",
+		// historical projects have no letter folder
+		"/eos/project/atlas/docs": "atlas",
+		"/eos/project/atlas":      "atlas",
+		"/eos/project/c/cbox":     "-",
+		"/eos/project/other/docs": "-",
+		"/eos/user/g/gonzalhu":    "-",
+		"-":                       "-",
+	}
+
+	for p, expected := range tuples {
+		got := "-"
+		if project := projectFromPath(p, projects); project != nil {
+			got = project.name
+		}
+		if got != expected {
+			t.Fatalf("%s: got:%s expected:%s", p, got, expected)
+		}
+	}
+}
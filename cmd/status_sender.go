@@ -311,16 +311,8 @@ func sendDegradedEmail(message string) {
 }
 
 func sendEmail(message string) {
-	user, password := getEmailCredentials()
-	from := getEmailSender()
 	to := getEmails()
-
-	smtpHost := "cernsmtp.cern.ch"
-	smtpPort := "587"
-
-	auth := smtp.PlainAuth("", user, password, smtpHost)
-
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, to, []byte(message))
+	err := sendEmailTo(to, message)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -332,6 +324,19 @@ func sendEmail(message string) {
 	}
 }
 
+// sendEmailTo sends the message with the configured sender through the CERN SMTP server.
+func sendEmailTo(to []string, message string) error {
+	user, password := getEmailCredentials()
+	from := getEmailSender()
+
+	smtpHost := "cernsmtp.cern.ch"
+	smtpPort := "587"
+
+	auth := smtp.PlainAuth("", user, password, smtpHost)
+
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, to, []byte(message))
+}
+
 // Removes status for the probe
 func removeStatus(probe string) {
 	getInstance().Update(func(tx *bolt.Tx) error {
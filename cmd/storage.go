@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/spf13/viper"
+)
+
+// Storage is the subset of EOS operations used by the commands.
+// The reva EOS client implements it for production MGMs and
+// fakeStorage implements it for offline runs (see storage_driver).
+type Storage interface {
+	List(ctx context.Context, username, path string) ([]*eosclient.FileInfo, error)
+	GetQuota(ctx context.Context, username, path string) (*eosclient.QuotaInfo, error)
+	DumpQuotas(ctx context.Context, path string) (map[string]*eosclient.QuotaInfo, error)
+	GetFileInfoByInode(ctx context.Context, username string, inode uint64) (*eosclient.FileInfo, error)
+	GetFileInfoByPath(ctx context.Context, username, path string) (*eosclient.FileInfo, error)
+	ListACLs(ctx context.Context, username, path string) ([]*acl.Entry, error)
+	AddACL(ctx context.Context, username, path string, a *acl.Entry) error
+	RemoveACL(ctx context.Context, username, path string, aclType string, recipient string) error
+	Read(ctx context.Context, username, path string) (io.ReadCloser, error)
+	Write(ctx context.Context, username, path string, stream io.ReadCloser) error
+	CreateDir(ctx context.Context, username, path string) error
+	Chown(ctx context.Context, username, chownUser, path string) error
+	ChownIDs(ctx context.Context, uid, gid uint64, path string) error
+	Remove(ctx context.Context, username, path string) error
+	Rename(ctx context.Context, username, oldPath, newPath string) error
+	SetAttr(ctx context.Context, username string, attr *eosclient.Attribute, recursive bool, path string) error
+	SetQuota(ctx context.Context, user, node string, maxBytes, maxFiles uint64) error
+	RmQuota(ctx context.Context, user, node string) error
+}
+
+// eosStorage adds to the reva EOS client the quota administration,
+// which reva does not implement.
+type eosStorage struct {
+	*eosclient.Client
+	opts *eosclient.Options
+}
+
+// SetQuota sets the user quota of the user on the quota node, /eos/project/
+func (s *eosStorage) SetQuota(ctx context.Context, user, node string, maxBytes, maxFiles uint64) error {
+	return s.quota(ctx, "set", "-u", user, "-v", strconv.FormatUint(maxBytes, 10), "-i", strconv.FormatUint(maxFiles, 10), "-p", node)
+}
+
+// RmQuota removes the user quota of the user from the quota node.
+func (s *eosStorage) RmQuota(ctx context.Context, user, node string) error {
+	return s.quota(ctx, "rm", "-u", user, "-p", node)
+}
+
+// ChownIDs changes the owner of the path to the uid and gid, unlike Chown
+// it does not look up the user on the local host.
+func (s *eosStorage) ChownIDs(ctx context.Context, uid, gid uint64, path string) error {
+	return s.run(ctx, "chown", fmt.Sprintf("%d:%d", uid, gid), path)
+}
+
+func (s *eosStorage) quota(ctx context.Context, args ...string) error {
+	return s.run(ctx, append([]string{"quota"}, args...)...)
+}
+
+// run runs the eos command as root with the binary and the environment of the reva client.
+func (s *eosStorage) run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, s.opts.EosBinary, append([]string{"-r", "0", "0"}, args...)...)
+	cmd.Env = []string{
+		"EOS_MGM_URL=" + s.opts.URL,
+	}
+	if s.opts.UseKeytab {
+		cmd.Env = append(cmd.Env, "XrdSecPROTOCOL="+s.opts.SecProtocol)
+		cmd.Env = append(cmd.Env, "XrdSecSSSKT="+s.opts.Keytab)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("eos %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func getStorageDriver() string {
+	driver := viper.GetString("storage_driver")
+	if driver == "" {
+		return "eos"
+	}
+	return driver
+}
+
+func getStorageFakeFile() string {
+	return viper.GetString("storage_fake_file")
+}
+
+func getEOS(mgm string) Storage {
+	switch getStorageDriver() {
+	case "eos":
+		eosClientOpts := &eosclient.Options{
+			URL: mgm,
+		}
+		// New fills the defaults of the options, like the eos binary
+		return &eosStorage{Client: eosclient.New(eosClientOpts), opts: eosClientOpts}
+	case "fake":
+		s, err := getFakeStorage(getStorageFakeFile(), mgm)
+		if err != nil {
+			er(err)
+		}
+		return s
+	default:
+		er("unknown storage_driver: " + getStorageDriver())
+	}
+	return nil
+}
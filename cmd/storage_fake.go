@@ -0,0 +1,503 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/acl"
+)
+
+// fakeStorage is an in-memory EOS namespace used to run the commands
+// without production MGMs. When a fixture file is configured with
+// storage_fake_file, the namespace of every MGM is loaded from it and
+// any modification is written back, so consecutive runs see the same state.
+//
+// The fixture file is a JSON object keyed by MGM URL:
+//
+//	{
+//	  "root://eoshome-g.cern.ch": {
+//	    "files": [
+//	      {"eos_file": "/eos/user/g", "IsDir": true, "inode": 2},
+//	      {"eos_file": "/eos/user/g/gonzalhu", "IsDir": true, "inode": 3, "uid": 1234, "gid": 1000, "sys_acl": "u:1234:rwx"}
+//	    ],
+//	    "quotas": {"/eos/user/": {"gonzalhu": {"AvailableBytes": 1000, "UsedBytes": 10}}},
+//	    "uids": {"gonzalhu": 1234}
+//	  }
+//	}
+type fakeStorage struct {
+	mgm string
+	db  *fakeDB
+}
+
+type fakeDB struct {
+	file      string
+	mu        sync.Mutex
+	instances map[string]*fakeInstance
+}
+
+type fakeInstance struct {
+	Files  []*fakeFile                                `json:"files"`
+	Quotas map[string]map[string]*eosclient.QuotaInfo `json:"quotas"`
+	UIDs   map[string]uint64                          `json:"uids,omitempty"`
+}
+
+type fakeFile struct {
+	eosclient.FileInfo
+	Data string `json:"data,omitempty"`
+}
+
+var fakeDBs = map[string]*fakeDB{}
+var fakeDBsMu sync.Mutex
+
+// getFakeStorage returns the fake namespace of the given MGM stored in file.
+// An empty file means a purely in-memory namespace that starts empty.
+func getFakeStorage(file, mgm string) (*fakeStorage, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	db, ok := fakeDBs[file]
+	if !ok {
+		db = &fakeDB{file: file, instances: map[string]*fakeInstance{}}
+		if file != "" {
+			data, err := ioutil.ReadFile(file)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			if len(data) > 0 {
+				if err := json.Unmarshal(data, &db.instances); err != nil {
+					return nil, err
+				}
+			}
+		}
+		fakeDBs[file] = db
+	}
+	return &fakeStorage{mgm: mgm, db: db}, nil
+}
+
+// instance returns the namespace of the MGM, the caller must hold the lock.
+func (s *fakeStorage) instance() *fakeInstance {
+	i, ok := s.db.instances[s.mgm]
+	if !ok {
+		i = &fakeInstance{}
+		s.db.instances[s.mgm] = i
+	}
+	if i.Quotas == nil {
+		i.Quotas = map[string]map[string]*eosclient.QuotaInfo{}
+	}
+	return i
+}
+
+// flush writes the namespaces back to the fixture file, the caller must hold the lock.
+func (s *fakeStorage) flush() error {
+	if s.db.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.db.instances, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.db.file, data, 0644)
+}
+
+func (s *fakeStorage) lookup(p string) *fakeFile {
+	p = path.Clean(p)
+	for _, f := range s.instance().Files {
+		if path.Clean(f.File) == p {
+			return f
+		}
+	}
+	return nil
+}
+
+// uid maps the username to its uid like EOS does for the user ACLs,
+// the caller must hold the lock.
+func (s *fakeStorage) uid(username string) (string, error) {
+	uid, ok := s.instance().UIDs[username]
+	if !ok {
+		return "", fmt.Errorf("user: unknown user %s", username)
+	}
+	return fmt.Sprintf("%d", uid), nil
+}
+
+// username maps the uid back to its username, the caller must hold the lock.
+func (s *fakeStorage) username(uid string) string {
+	for u, id := range s.instance().UIDs {
+		if fmt.Sprintf("%d", id) == uid {
+			return u
+		}
+	}
+	return uid
+}
+
+func (s *fakeStorage) nextInode() uint64 {
+	var max uint64
+	for _, f := range s.instance().Files {
+		if f.Inode > max {
+			max = f.Inode
+		}
+	}
+	return max + 1
+}
+
+// info returns a copy of the file metadata as the MGM would return it.
+func (s *fakeStorage) info(f *fakeFile) *eosclient.FileInfo {
+	fi := f.FileInfo
+	fi.File = path.Clean(fi.File)
+	fi.Instance = s.mgm
+	if fi.FID == 0 {
+		fi.FID = fi.Inode
+	}
+	fi.Attrs = map[string]string{}
+	for k, v := range f.Attrs {
+		fi.Attrs[k] = v
+	}
+	return &fi
+}
+
+func (s *fakeStorage) List(ctx context.Context, username, p string) ([]*eosclient.FileInfo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.lookup(p) == nil {
+		return nil, errtypes.NotFound(p)
+	}
+
+	p = path.Clean(p)
+	finfos := []*eosclient.FileInfo{}
+	for _, f := range s.instance().Files {
+		if path.Dir(path.Clean(f.File)) == p && path.Clean(f.File) != p {
+			finfos = append(finfos, s.info(f))
+		}
+	}
+	return finfos, nil
+}
+
+func (s *fakeStorage) GetQuota(ctx context.Context, username, p string) (*eosclient.QuotaInfo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for space, users := range s.instance().Quotas {
+		if !strings.HasPrefix(p, space) {
+			continue
+		}
+		if q, ok := users[username]; ok {
+			qi := *q
+			return &qi, nil
+		}
+	}
+	return &eosclient.QuotaInfo{}, nil
+}
+
+func (s *fakeStorage) DumpQuotas(ctx context.Context, p string) (map[string]*eosclient.QuotaInfo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	quotas := map[string]*eosclient.QuotaInfo{}
+	for space, users := range s.instance().Quotas {
+		if !strings.HasPrefix(p, space) {
+			continue
+		}
+		for u, q := range users {
+			qi := *q
+			quotas[u] = &qi
+		}
+	}
+	return quotas, nil
+}
+
+func (s *fakeStorage) GetFileInfoByInode(ctx context.Context, username string, inode uint64) (*eosclient.FileInfo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, f := range s.instance().Files {
+		if f.Inode == inode {
+			return s.info(f), nil
+		}
+	}
+	return nil, errtypes.NotFound(fmt.Sprintf("inode:%d", inode))
+}
+
+func (s *fakeStorage) GetFileInfoByPath(ctx context.Context, username, p string) (*eosclient.FileInfo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return nil, errtypes.NotFound(p)
+	}
+	return s.info(f), nil
+}
+
+func (s *fakeStorage) ListACLs(ctx context.Context, username, p string) ([]*acl.Entry, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return nil, errtypes.NotFound(p)
+	}
+	acls, err := acl.Parse(f.SysACL, acl.ShortTextForm)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range acls.Entries {
+		if e.Type == acl.TypeUser {
+			e.Qualifier = s.username(e.Qualifier)
+		}
+	}
+	return acls.Entries, nil
+}
+
+// AddACL sets the entry in the sys.acl, the usernames are stored as uids like EOS does.
+func (s *fakeStorage) AddACL(ctx context.Context, username, p string, a *acl.Entry) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return errtypes.NotFound(p)
+	}
+	acls, err := acl.Parse(f.SysACL, acl.ShortTextForm)
+	if err != nil {
+		return err
+	}
+	qualifier := a.Qualifier
+	if a.Type == acl.TypeUser {
+		if qualifier, err = s.uid(qualifier); err != nil {
+			return err
+		}
+	}
+	if err := acls.SetEntry(a.Type, qualifier, a.Permissions); err != nil {
+		return err
+	}
+	f.SysACL = acls.Serialize()
+	return s.flush()
+}
+
+func (s *fakeStorage) RemoveACL(ctx context.Context, username, p string, aclType string, recipient string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return errtypes.NotFound(p)
+	}
+	acls, err := acl.Parse(f.SysACL, acl.ShortTextForm)
+	if err != nil {
+		return err
+	}
+	if aclType == acl.TypeUser {
+		if recipient, err = s.uid(recipient); err != nil {
+			return err
+		}
+	}
+	acls.DeleteEntry(aclType, recipient)
+	f.SysACL = acls.Serialize()
+	return s.flush()
+}
+
+func (s *fakeStorage) Read(ctx context.Context, username, p string) (io.ReadCloser, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil || f.IsDir {
+		return nil, errtypes.NotFound(p)
+	}
+	return ioutil.NopCloser(strings.NewReader(f.Data)), nil
+}
+
+func (s *fakeStorage) Write(ctx context.Context, username, p string, stream io.ReadCloser) error {
+	defer stream.Close()
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, stream); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if parent := s.lookup(path.Dir(p)); parent == nil || !parent.IsDir {
+		return errtypes.NotFound(path.Dir(p))
+	}
+
+	f := s.lookup(p)
+	if f == nil {
+		f = &fakeFile{}
+		f.File = path.Clean(p)
+		f.Inode = s.nextInode()
+		s.instance().Files = append(s.instance().Files, f)
+	}
+	if f.IsDir {
+		return errtypes.AlreadyExists(p)
+	}
+	f.Data = buf.String()
+	f.Size = uint64(buf.Len())
+	return s.flush()
+}
+
+func (s *fakeStorage) CreateDir(ctx context.Context, username, p string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// behaves like mkdir -p
+	var parents []string
+	for dir := path.Clean(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		parents = append([]string{dir}, parents...)
+	}
+	for _, dir := range parents {
+		f := s.lookup(dir)
+		if f == nil {
+			f = &fakeFile{}
+			f.File = dir
+			f.IsDir = true
+			f.Inode = s.nextInode()
+			s.instance().Files = append(s.instance().Files, f)
+			continue
+		}
+		if !f.IsDir {
+			return errtypes.AlreadyExists(dir)
+		}
+	}
+	return s.flush()
+}
+
+// Chown sets the uid mapped from the username by the uids of the fixture, the gid is kept.
+func (s *fakeStorage) Chown(ctx context.Context, username, chownUser, p string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return errtypes.NotFound(p)
+	}
+	uid, ok := s.instance().UIDs[chownUser]
+	if !ok {
+		return fmt.Errorf("user: unknown user %s", chownUser)
+	}
+	f.UID = uid
+	return s.flush()
+}
+
+func (s *fakeStorage) ChownIDs(ctx context.Context, uid, gid uint64, p string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	f := s.lookup(p)
+	if f == nil {
+		return errtypes.NotFound(p)
+	}
+	f.UID, f.GID = uid, gid
+	return s.flush()
+}
+
+func (s *fakeStorage) Remove(ctx context.Context, username, p string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.lookup(p) == nil {
+		return errtypes.NotFound(p)
+	}
+
+	// behaves like rm -r
+	p = path.Clean(p)
+	files := []*fakeFile{}
+	for _, f := range s.instance().Files {
+		fp := path.Clean(f.File)
+		if fp != p && !strings.HasPrefix(fp, p+"/") {
+			files = append(files, f)
+		}
+	}
+	s.instance().Files = files
+	return s.flush()
+}
+
+func (s *fakeStorage) SetQuota(ctx context.Context, user, node string, maxBytes, maxFiles uint64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	users, ok := s.instance().Quotas[node]
+	if !ok {
+		users = map[string]*eosclient.QuotaInfo{}
+		s.instance().Quotas[node] = users
+	}
+	q, ok := users[user]
+	if !ok {
+		q = &eosclient.QuotaInfo{}
+		users[user] = q
+	}
+	q.AvailableBytes, q.AvailableInodes = int(maxBytes), int(maxFiles)
+	return s.flush()
+}
+
+func (s *fakeStorage) RmQuota(ctx context.Context, user, node string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if users, ok := s.instance().Quotas[node]; ok {
+		delete(users, user)
+	}
+	return s.flush()
+}
+
+func (s *fakeStorage) Rename(ctx context.Context, username, oldPath, newPath string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	oldPath, newPath = path.Clean(oldPath), path.Clean(newPath)
+	if s.lookup(oldPath) == nil {
+		return errtypes.NotFound(oldPath)
+	}
+	if s.lookup(newPath) != nil {
+		return errtypes.AlreadyExists(newPath)
+	}
+	if parent := s.lookup(path.Dir(newPath)); parent == nil || !parent.IsDir {
+		return errtypes.NotFound(path.Dir(newPath))
+	}
+
+	for _, f := range s.instance().Files {
+		fp := path.Clean(f.File)
+		if fp == oldPath || strings.HasPrefix(fp, oldPath+"/") {
+			f.File = newPath + strings.TrimPrefix(fp, oldPath)
+		}
+	}
+	return s.flush()
+}
+
+// SetAttr sets the attribute, sys.acl is stored as the SysACL of the file.
+func (s *fakeStorage) SetAttr(ctx context.Context, username string, attr *eosclient.Attribute, recursive bool, p string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	p = path.Clean(p)
+	if s.lookup(p) == nil {
+		return errtypes.NotFound(p)
+	}
+
+	key := fmt.Sprintf("%s.%s", attr.Type, attr.Key)
+	for _, f := range s.instance().Files {
+		fp := path.Clean(f.File)
+		if fp != p && !(recursive && f.IsDir && strings.HasPrefix(fp, p+"/")) {
+			continue
+		}
+		if key == "sys.acl" {
+			f.SysACL = attr.Val
+			continue
+		}
+		if f.Attrs == nil {
+			f.Attrs = map[string]string{}
+		}
+		f.Attrs[key] = attr.Val
+	}
+	return s.flush()
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/storage/acl"
+)
+
+func TestFakeStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cernboxcop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "fake.json")
+	mgm := "root://eoshome-g.cern.ch"
+
+	s, err := getFakeStorage(file, mgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := s.CreateDir(ctx, "root", "/eos/user/g/gonzalhu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(ctx, "root", "/eos/user/g/gonzalhu/notes.txt", ioutil.NopCloser(strings.NewReader("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh namespace loaded from the same fixture file sees the changes
+	delete(fakeDBs, file)
+	s, err = getFakeStorage(file, mgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mds, err := s.List(ctx, "root", "/eos/user/g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mds) != 1 || mds[0].File != "/eos/user/g/gonzalhu" || !mds[0].IsDir {
+		t.Fatalf("got:%+v expected:/eos/user/g/gonzalhu", mds)
+	}
+
+	fi, err := s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Instance != mgm {
+		t.Fatalf("got:%s expected:%s", fi.Instance, mgm)
+	}
+
+	fi, err = s.GetFileInfoByInode(ctx, "root", fi.Inode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := s.Read(ctx, "root", fi.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(body)
+	if string(data) != "hello" {
+		t.Fatalf("got:%s expected:hello", data)
+	}
+
+	if err := s.ChownIDs(ctx, 1234, 1000, "/eos/user/g/gonzalhu"); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ = s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu")
+	if fi.UID != 1234 || fi.GID != 1000 {
+		t.Fatalf("got:%d:%d expected:1234:1000", fi.UID, fi.GID)
+	}
+
+	// like EOS the user ACLs are stored with the uid
+	s.instance().UIDs = map[string]uint64{"gonzalhu": 1234}
+	if err := s.Chown(ctx, "root", "gonzalhu", "/eos/user/g/gonzalhu/notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ = s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu/notes.txt")
+	if fi.UID != 1234 {
+		t.Fatalf("got:%d expected:1234", fi.UID)
+	}
+	if err := s.AddACL(ctx, "root", "/eos/user/g/gonzalhu", &acl.Entry{Type: acl.TypeUser, Qualifier: "gonzalhu", Permissions: "rx"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddACL(ctx, "root", "/eos/user/g/gonzalhu", &acl.Entry{Type: acl.TypeUser, Qualifier: "nobody", Permissions: "rx"}); err == nil {
+		t.Fatal("expected error for a user without uid")
+	}
+	if err := s.AddACL(ctx, "root", "/eos/user/g/gonzalhu", &acl.Entry{Type: "egroup", Qualifier: "cernbox-admins", Permissions: "rwx+d"}); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ = s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu")
+	if fi.SysACL != "u:1234:rx,egroup:cernbox-admins:rwx+d" {
+		t.Fatalf("got:%s expected:u:1234:rx,egroup:cernbox-admins:rwx+d", fi.SysACL)
+	}
+	entries, _ := s.ListACLs(ctx, "root", "/eos/user/g/gonzalhu")
+	if len(entries) != 2 || entries[0].Qualifier != "gonzalhu" {
+		t.Fatalf("got:%+v expected:gonzalhu", entries[0])
+	}
+	if err := s.RemoveACL(ctx, "root", "/eos/user/g/gonzalhu", acl.TypeUser, "gonzalhu"); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ = s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu")
+	if fi.SysACL != "egroup:cernbox-admins:rwx+d" {
+		t.Fatalf("got:%s expected:egroup:cernbox-admins:rwx+d", fi.SysACL)
+	}
+
+	if err := s.SetQuota(ctx, "gonzalhu", "/eos/user/", 1000, 10); err != nil {
+		t.Fatal(err)
+	}
+	q, _ := s.GetQuota(ctx, "gonzalhu", "/eos/user/g/gonzalhu")
+	if q.AvailableBytes != 1000 {
+		t.Fatalf("got:%d expected:1000", q.AvailableBytes)
+	}
+
+	if err := s.Remove(ctx, "root", "/eos/user/g/gonzalhu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu/notes.txt"); err == nil {
+		t.Fatal("expected not found after remove")
+	}
+
+	// other instances are isolated
+	other, _ := getFakeStorage(file, "root://eoshome-a.cern.ch")
+	if _, err := other.GetFileInfoByPath(ctx, "root", "/eos/user/g/gonzalhu"); err == nil {
+		t.Fatal("expected not found on another instance")
+	}
+}
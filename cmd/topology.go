@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(topologyCmd)
+	topologyCmd.AddCommand(topologyShowCmd)
+}
+
+const (
+	kindHome    = "home"
+	kindProject = "project"
+
+	// initials are the letters used to shard homes and projects, /eos/user/<letter>/<name>
+	initials = "abcdefghijklmnopqrstuvwxyz"
+)
+
+// eosNamespace describes how one kind of space (user homes or project spaces)
+// is spread across EOS instances.
+type eosNamespace struct {
+	Kind    string            `mapstructure:"kind"`
+	Prefix  string            `mapstructure:"prefix"`  // /eos/user
+	MGM     string            `mapstructure:"mgm"`     // root://eoshome-%s.cern.ch, %s is the letter
+	Letters map[string]string `mapstructure:"letters"` // letter -> MGM URL, overrides the template
+
+	SharePrefix string `mapstructure:"share_prefix"` // fileid_prefix of new shares, %s is the letter
+}
+
+// eosInstance is a physical EOS instance, used by the metrics commands
+// and to resolve instance names found in shares or OnlyOffice keys.
+type eosInstance struct {
+	Name    string   `mapstructure:"name"`
+	Kind    string   `mapstructure:"kind"`
+	MGM     string   `mapstructure:"mgm"`
+	Aliases []string `mapstructure:"aliases"`
+}
+
+// eosTopology is read from the "topology" section of the config:
+//
+//	topology:
+//	  home:
+//	    prefix: /eos/user
+//	    mgm: root://eoshome-%s.cern.ch
+//	    letters:
+//	      a: root://eoshome-i01.cern.ch
+//	  project:
+//	    prefix: /eos/project
+//	    mgm: root://eosproject-%s.cern.ch
+//	    share_prefix: newproject-%s
+//	  instance_mgm: root://%s.cern.ch
+//	  http_port: 8000
+//	  aliases:
+//	    new: eos
+//	  instances:
+//	    - name: eoshome-i00
+//	      kind: home
+//	      mgm: root://eoshome-i00.cern.ch
+//	      aliases: [eoshome-a]
+//
+// Missing entries take the historical CERN layout.
+type eosTopology struct {
+	Home        *eosNamespace     `mapstructure:"home"`
+	Project     *eosNamespace     `mapstructure:"project"`
+	InstanceMGM string            `mapstructure:"instance_mgm"` // template for instances not listed
+	HTTPPort    int               `mapstructure:"http_port"`    // port of the /proc fileinfo of the MGMs
+	Aliases     map[string]string `mapstructure:"aliases"`      // instance name prefix rewrites, newproject-c => eosproject-c
+	Instances   []*eosInstance    `mapstructure:"instances"`
+}
+
+var topology *eosTopology
+var topologyOnce sync.Once
+
+// getTopology returns the topology from the config, only read once.
+func getTopology() *eosTopology {
+	topologyOnce.Do(func() {
+		t := &eosTopology{}
+		if err := viper.UnmarshalKey("topology", t); err != nil {
+			er(err)
+		}
+		t.setDefaults()
+		topology = t
+	})
+	return topology
+}
+
+func (t *eosTopology) setDefaults() {
+	if t.Home == nil {
+		t.Home = &eosNamespace{}
+	}
+	if t.Project == nil {
+		t.Project = &eosNamespace{}
+	}
+	t.Home.Kind, t.Project.Kind = kindHome, kindProject
+	if t.Home.Prefix == "" {
+		t.Home.Prefix = "/eos/user"
+	}
+	if t.Home.MGM == "" {
+		t.Home.MGM = "root://eoshome-%s.cern.ch"
+	}
+	if t.Project.Prefix == "" {
+		t.Project.Prefix = "/eos/project"
+	}
+	if t.Project.MGM == "" {
+		t.Project.MGM = "root://eosproject-%s.cern.ch"
+	}
+	if t.Project.SharePrefix == "" {
+		// the web app stores the project shares with the new prefix
+		t.Project.SharePrefix = "newproject-%s"
+	}
+	t.Home.Prefix = path.Clean(t.Home.Prefix)
+	t.Project.Prefix = path.Clean(t.Project.Prefix)
+
+	if t.InstanceMGM == "" {
+		t.InstanceMGM = "root://%s.cern.ch"
+	}
+	if t.HTTPPort == 0 {
+		t.HTTPPort = 8000
+	}
+	// the configured aliases are merged over the built-in ones
+	aliases := map[string]string{"new": "eos"}
+	for k, v := range t.Aliases {
+		aliases[k] = v
+	}
+	t.Aliases = aliases
+	if len(t.Instances) == 0 {
+		for _, name := range []string{"eoshome-i00", "eoshome-i01", "eoshome-i02", "eoshome-i03", "eoshome-i04"} {
+			t.Instances = append(t.Instances, &eosInstance{Name: name, Kind: kindHome})
+		}
+		for _, name := range []string{"eosproject-i00", "eosproject-i01", "eosproject-i02"} {
+			t.Instances = append(t.Instances, &eosInstance{Name: name, Kind: kindProject})
+		}
+	}
+	for _, i := range t.Instances {
+		if i.MGM == "" {
+			i.MGM = fmt.Sprintf(t.InstanceMGM, i.Name)
+		}
+	}
+}
+
+// namespace returns the namespace for the given kind (home or project).
+func (t *eosTopology) namespace(kind string) *eosNamespace {
+	if kind == kindProject {
+		return t.Project
+	}
+	return t.Home
+}
+
+// mgm returns the MGM URL serving the given letter.
+func (ns *eosNamespace) mgm(letter string) string {
+	if mgm, ok := ns.Letters[letter]; ok {
+		return mgm
+	}
+	return fmt.Sprintf(ns.MGM, letter)
+}
+
+// letters returns all the letters the namespace is sharded by.
+func (ns *eosNamespace) letters() []string {
+	extra := []string{}
+	for l := range ns.Letters {
+		if !strings.Contains(initials, l) {
+			extra = append(extra, l)
+		}
+	}
+	sort.Strings(extra)
+	return append(strings.Split(initials, ""), extra...)
+}
+
+// dir returns the directory holding all spaces of the given letter, /eos/user/g
+func (ns *eosNamespace) dir(letter string) string {
+	return path.Join(ns.Prefix, letter)
+}
+
+// path returns the full path of the space with the given name, /eos/user/g/gonzalhu
+func (ns *eosNamespace) path(name string) string {
+	return path.Join(ns.Prefix, string(name[0]), name)
+}
+
+// mgmForUser returns the MGM URL holding the home of the user.
+func (t *eosTopology) mgmForUser(username string) string {
+	return t.Home.mgm(string(username[0]))
+}
+
+// mgmForProject returns the MGM URL holding the project space.
+func (t *eosTopology) mgmForProject(name string) string {
+	return t.Project.mgm(string(name[0]))
+}
+
+// mgmForPath returns the MGM URL holding the home or project path,
+// /eos/user/g/gonzalhu/docs => root://eoshome-g.cern.ch
+func (t *eosTopology) mgmForPath(p string) (string, error) {
+	ns, letter, err := t.namespaceForPath(p)
+	if err != nil {
+		return "", err
+	}
+	return ns.mgm(letter), nil
+}
+
+// sharePrefixForPath returns the fileid_prefix the web app stores in the
+// shares of the path, /eos/project/c/cernbox => newproject-c
+func (t *eosTopology) sharePrefixForPath(p string) (string, error) {
+	ns, letter, err := t.namespaceForPath(p)
+	if err != nil {
+		return "", err
+	}
+	if ns.SharePrefix != "" {
+		return fmt.Sprintf(ns.SharePrefix, letter), nil
+	}
+	return t.instanceForMGM(ns.mgm(letter)), nil
+}
+
+// namespaceForPath returns the namespace and the letter holding the path.
+func (t *eosTopology) namespaceForPath(p string) (*eosNamespace, string, error) {
+	p = path.Clean(p)
+	for _, ns := range []*eosNamespace{t.Home, t.Project} {
+		if !strings.HasPrefix(p, ns.Prefix+"/") {
+			continue
+		}
+		letter := strings.Split(strings.TrimPrefix(p, ns.Prefix+"/"), "/")[0]
+		if len(letter) != 1 {
+			// historical projects directly under the prefix, /eos/project/cernbox
+			letter = string(letter[0])
+		}
+		return ns, letter, nil
+	}
+	return nil, "", fmt.Errorf("path %q is not inside %s or %s", p, t.Home.Prefix, t.Project.Prefix)
+}
+
+// canonicalInstance applies the configured aliases to an instance name
+// as found in share prefixes or OnlyOffice keys, newproject-c => eosproject-c
+func (t *eosTopology) canonicalInstance(name string) string {
+	var match string
+	for alias := range t.Aliases {
+		if strings.HasPrefix(name, alias) && len(alias) > len(match) {
+			match = alias
+		}
+	}
+	if match != "" {
+		name = t.Aliases[match] + strings.TrimPrefix(name, match)
+	}
+	for _, i := range t.Instances {
+		for _, a := range i.Aliases {
+			if a == name {
+				return i.Name
+			}
+		}
+	}
+	return name
+}
+
+// mgmForInstance returns the MGM URL of the instance with the given name or alias.
+func (t *eosTopology) mgmForInstance(name string) string {
+	name = t.canonicalInstance(name)
+	for _, i := range t.Instances {
+		if i.Name == name {
+			return i.MGM
+		}
+	}
+	return fmt.Sprintf(t.InstanceMGM, name)
+}
+
+// instanceForMGM returns the instance name of the MGM URL as stored in the
+// share prefixes, root://eoshome-g.cern.ch => eoshome-g
+func (t *eosTopology) instanceForMGM(mgm string) string {
+	for _, i := range t.Instances {
+		if i.MGM == mgm {
+			return i.Name
+		}
+	}
+	return strings.Split(mgmHost(mgm), ".")[0]
+}
+
+// instances returns the instances of the given kind, all of them if kind is empty.
+func (t *eosTopology) instances(kind string) []*eosInstance {
+	instances := []*eosInstance{}
+	for _, i := range t.Instances {
+		if kind == "" || i.Kind == kind {
+			instances = append(instances, i)
+		}
+	}
+	return instances
+}
+
+// kindOfMGM returns whether the MGM serves homes or projects, empty if unknown.
+func (t *eosTopology) kindOfMGM(mgm string) string {
+	for _, ns := range []*eosNamespace{t.Home, t.Project} {
+		for _, l := range ns.letters() {
+			if ns.mgm(l) == mgm {
+				return ns.Kind
+			}
+		}
+	}
+	for _, i := range t.Instances {
+		if i.MGM == mgm {
+			return i.Kind
+		}
+	}
+	return ""
+}
+
+// mgmHost returns the hostname of the MGM URL, root://eoshome-a.cern.ch => eoshome-a.cern.ch
+func mgmHost(mgm string) string {
+	u, err := url.Parse(mgm)
+	if err != nil || u.Host == "" {
+		return strings.TrimPrefix(mgm, "root://")
+	}
+	return u.Hostname()
+}
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "EOS instances topology",
+}
+
+var topologyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Shows the effective mapping of letters and instances to MGMs",
+	Run: func(cmd *cobra.Command, args []string) {
+		tableOutputOnly(cmd)
+		t := getTopology()
+
+		cols := []string{"KIND", "PREFIX", "LETTER", "MGM"}
+		rows := [][]string{}
+		for _, ns := range []*eosNamespace{t.Home, t.Project} {
+			for _, l := range ns.letters() {
+				rows = append(rows, []string{ns.Kind, ns.dir(l), l, ns.mgm(l)})
+			}
+		}
+		pretty(cols, rows)
+		fmt.Println()
+
+		cols = []string{"INSTANCE", "KIND", "MGM", "ALIASES"}
+		rows = [][]string{}
+		for _, i := range t.Instances {
+			rows = append(rows, []string{i.Name, i.Kind, i.MGM, strings.Join(i.Aliases, ",")})
+		}
+		pretty(cols, rows)
+		fmt.Println()
+
+		cols = []string{"ALIAS", "CANONICAL"}
+		rows = [][]string{}
+		keys := make([]string, 0, len(t.Aliases))
+		for k := range t.Aliases {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			rows = append(rows, []string{k + "*", t.Aliases[k] + "*"})
+		}
+		pretty(cols, rows)
+	},
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
@@ -30,6 +31,9 @@ var userCheckCmd = &cobra.Command{
 		if len(args) != 1 {
 			exit(cmd)
 		}
+		if deep, _ := cmd.Flags().GetBool("deep"); deep {
+			tableOutputOnly(cmd)
+		}
 
 		lc := getLDAP()
 		username := strings.TrimSpace(args[0])
@@ -41,6 +45,9 @@ var userCheckCmd = &cobra.Command{
 		}
 		prettyUser(infos...)
 
+		if deep, _ := cmd.Flags().GetBool("deep"); deep {
+			prettyHomeChecks(checkHome(username))
+		}
 	},
 }
 
@@ -72,8 +79,7 @@ var userGroupsCmd = &cobra.Command{
 }
 
 func getHomePath(username string) string {
-	letter := string(username[0])
-	return fmt.Sprintf("/eos/user/%s/%s", letter, username)
+	return getTopology().Home.path(username)
 }
 
 func isMigrated(username string) bool {
@@ -101,9 +107,16 @@ func isMigrated(username string) bool {
 }
 
 func getUser(l *ldap.Conn, uid string) *userInfo {
-
 	// Search for the given username
-	searchTerm := fmt.Sprintf("(&(objectClass=user)(samaccountname=%s))", uid)
+	return searchUser(l, fmt.Sprintf("(&(objectClass=user)(samaccountname=%s))", uid))
+}
+
+// getUserByUIDNumber returns the account with the given unix uid, as found in EOS ACLs.
+func getUserByUIDNumber(l *ldap.Conn, uidNumber string) *userInfo {
+	return searchUser(l, fmt.Sprintf("(&(objectClass=user)(uidNumber=%s))", uidNumber))
+}
+
+func searchUser(l *ldap.Conn, searchTerm string) *userInfo {
 	searchRequest := ldap.NewSearchRequest(
 		"OU=Users,OU=Organic Units,DC=cern,DC=ch",
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
@@ -158,6 +171,11 @@ func getUser(l *ldap.Conn, uid string) *userInfo {
 		if attr.Name == "gidNumber" {
 			ui.GID = attr.Values[0]
 		}
+		if attr.Name == "userAccountControl" {
+			// ACCOUNTDISABLE flag
+			uac, _ := strconv.Atoi(attr.Values[0])
+			ui.Disabled = uac&0x2 != 0
+		}
 	}
 
 	return ui
@@ -263,6 +281,67 @@ func getUserGroups(uid string) []string {
 	return gids
 }
 
+// getEgroupMembers returns the DNs of the direct members of the e-group
+// and false if the e-group does not exist.
+func getEgroupMembers(l *ldap.Conn, egroup string) ([]string, bool) {
+	searchRequest := ldap.NewSearchRequest(
+		"OU=e-groups,OU=Workgroups,DC=cern,DC=ch",
+		ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=Group)(cn=%s))", ldap.EscapeFilter(egroup)),
+		[]string{"member"},
+		nil,
+	)
+
+	sr, err := l.SearchWithPaging(searchRequest, 1000000)
+	if err != nil {
+		er(err)
+	}
+
+	if len(sr.Entries) == 0 {
+		return nil, false
+	}
+	return sr.Entries[0].GetAttributeValues("member"), true
+}
+
+// expandEgroupMembers returns the accounts of the e-group including the members
+// of nested e-groups, mapped to the e-group they are a direct member of,
+// and false if the e-group does not exist.
+func expandEgroupMembers(l *ldap.Conn, egroup string) (map[string]string, bool) {
+	members := map[string]string{}
+	visited := map[string]bool{}
+
+	var expand func(egroup string) bool
+	expand = func(egroup string) bool {
+		if visited[egroup] {
+			return true
+		}
+		visited[egroup] = true
+
+		dns, exists := getEgroupMembers(l, egroup)
+		if !exists {
+			return false
+		}
+		for _, dn := range dns {
+			cn := extractCN(dn)
+			if strings.Contains(strings.ToLower(dn), "ou=e-groups") {
+				if !expand(cn) {
+					fmt.Fprintf(os.Stderr, "nested e-group %s of %s does not exist\n", cn, egroup)
+				}
+				continue
+			}
+			if _, ok := members[cn]; !ok {
+				members[cn] = egroup
+			}
+		}
+		return true
+	}
+
+	if !expand(egroup) {
+		return nil, false
+	}
+	return members, true
+}
+
 func newUserInfo() *userInfo {
 	return &userInfo{
 		AccountOwner: &userInfo{},
@@ -281,6 +360,20 @@ type userInfo struct {
 	AccountOwner   *userInfo
 	AccountOwnerDN string
 	Phone          string
+	Disabled       bool
+}
+
+// unixIDs returns the uidNumber and gidNumber of the account in LDAP.
+func (ui *userInfo) unixIDs() (uint64, uint64, error) {
+	uid, err := strconv.ParseUint(ui.UID, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid uidNumber %q of %s", ui.UID, ui.Account)
+	}
+	gid, err := strconv.ParseUint(ui.GID, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid gidNumber %q of %s", ui.GID, ui.Account)
+	}
+	return uid, gid, nil
 }
 
 func (ui *userInfo) accountTypeHuman() string {
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/eosclient"
+	"github.com/cs3org/reva/pkg/storage/acl"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	userCmd.AddCommand(userProvisionCmd)
+
+	userCheckCmd.Flags().BoolP("deep", "", false, "also check the EOS home: folder, owner, quota and sys.acl")
+	userProvisionCmd.Flags().BoolP("dry-run", "", false, "only show the checks and what would be fixed")
+}
+
+// The homes are provisioned with the quota of the config:
+//
+//	home_default_quota: 1TB
+//	home_default_files: 1000000
+
+func getHomeDefaultQuota() (uint64, uint64) {
+	quota := viper.GetString("home_default_quota")
+	if quota == "" {
+		quota = "1TB"
+	}
+	bytes, err := humanize.ParseBytes(quota)
+	if err != nil {
+		er(fmt.Sprintf("invalid home_default_quota: %v", err))
+	}
+	files := uint64(viper.GetInt64("home_default_files"))
+	if files == 0 {
+		files = 1000000
+	}
+	return bytes, files
+}
+
+// homeCheck is one check of a user home, fix repairs it when it fails.
+type homeCheck struct {
+	name   string
+	ok     bool
+	detail string
+	fix    func() error
+}
+
+var userProvisionCmd = &cobra.Command{
+	Use:   "provision <username>",
+	Short: "Creates or repairs the EOS home of the user: folder, owner, quota and sys.acl",
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if len(args) != 1 {
+			exit(cmd)
+		}
+		tableOutputOnly(cmd)
+
+		username := strings.TrimSpace(args[0])
+		checks := checkHome(username)
+		prettyHomeChecks(checks)
+
+		failed := []*homeCheck{}
+		for _, c := range checks {
+			if !c.ok {
+				if c.fix == nil {
+					er(fmt.Sprintf("%s: %s, it cannot be fixed by provisioning", c.name, c.detail))
+				}
+				failed = append(failed, c)
+			}
+		}
+		if len(failed) == 0 {
+			fmt.Printf("home of %s is healthy\n", username)
+			return
+		}
+		if dryRun {
+			return
+		}
+		checkAuditLog()
+
+		fixed := []string{}
+		for _, c := range failed {
+			fmt.Fprintf(os.Stderr, "fixing %s\n", c.name)
+			if err := c.fix(); err != nil {
+				er(fmt.Sprintf("fixing %s: %v", c.name, err))
+			}
+			fixed = append(fixed, c.name)
+		}
+
+		auditLog("user-provision", map[string]string{
+			"username": username,
+			"path":     getHomePath(username),
+			"fixed":    strings.Join(fixed, ","),
+		})
+
+		// the checks after the fixes are the proof for the ticket
+		checks = checkHome(username)
+		prettyHomeChecks(checks)
+		for _, c := range checks {
+			if !c.ok {
+				fmt.Fprintf(os.Stderr, "Error: %s still fails after provisioning\n", c.name)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// checkHome runs the checks of the home in order. Without a valid LDAP
+// account the EOS checks are not run, and a missing home fails them all.
+func checkHome(username string) []*homeCheck {
+	lc := getLDAP()
+	defer lc.Close()
+	ui := getUser(lc, username)
+
+	checks := []*homeCheck{}
+	ldapCheck := &homeCheck{name: "ldap", ok: true, detail: fmt.Sprintf("uid=%s gid=%s", ui.UID, ui.GID)}
+	switch {
+	case ui.Account == "":
+		ldapCheck.ok, ldapCheck.detail = false, "account not found"
+	case ui.UID == "" || ui.GID == "":
+		ldapCheck.ok, ldapCheck.detail = false, "missing uidNumber or gidNumber"
+	}
+	checks = append(checks, ldapCheck)
+	if !ldapCheck.ok {
+		return checks
+	}
+
+	mgm := getTopology().mgmForUser(username)
+	client := getEOS(mgm)
+	home := getHomePath(username)
+	node := getTopology().Home.Prefix + "/"
+
+	ctx, cancel := context.WithTimeout(getCtx(), time.Second*30)
+	defer cancel()
+
+	existsCheck := &homeCheck{name: "exists", detail: fmt.Sprintf("%s on %s", home, mgm)}
+	existsCheck.fix = withEOSTimeout(func(ctx context.Context) error {
+		return client.CreateDir(ctx, "root", home)
+	})
+	fi, err := client.GetFileInfoByPath(ctx, "root", home)
+	switch {
+	case err != nil:
+		existsCheck.detail = fmt.Sprintf("%s not found on %s", home, mgm)
+	case !fi.IsDir:
+		existsCheck.detail, existsCheck.fix = fmt.Sprintf("%s is not a folder", home), nil
+	default:
+		existsCheck.ok = true
+	}
+	checks = append(checks, existsCheck)
+	if fi == nil {
+		// a missing home fails all the following checks
+		fi = &eosclient.FileInfo{}
+	}
+
+	ownerCheck := &homeCheck{name: "owner", detail: fmt.Sprintf("uid=%d gid=%d", fi.UID, fi.GID)}
+	ownerCheck.ok = fmt.Sprintf("%d", fi.UID) == ui.UID && fmt.Sprintf("%d", fi.GID) == ui.GID
+	ownerCheck.fix = withEOSTimeout(func(ctx context.Context) error {
+		// the ids of LDAP, the MGM host may not know the user
+		uid, gid, err := ui.unixIDs()
+		if err != nil {
+			return err
+		}
+		return client.ChownIDs(ctx, uid, gid, home)
+	})
+	checks = append(checks, ownerCheck)
+
+	quotaCheck := &homeCheck{name: "quota"}
+	quotaCheck.fix = withEOSTimeout(func(ctx context.Context) error {
+		bytes, files := getHomeDefaultQuota()
+		return client.SetQuota(ctx, username, node, bytes, files)
+	})
+	q, err := client.GetQuota(ctx, username, node)
+	switch {
+	case err != nil:
+		quotaCheck.detail = err.Error()
+	case q.AvailableBytes == 0:
+		quotaCheck.detail = "no quota on " + node
+	default:
+		quotaCheck.ok = true
+		quotaCheck.detail = fmt.Sprintf("%s used of %s", humanQuota(q.UsedBytes), humanQuota(q.AvailableBytes))
+	}
+	checks = append(checks, quotaCheck)
+
+	aclCheck := &homeCheck{name: "acl", detail: fi.SysACL}
+	aclCheck.fix = withEOSTimeout(func(ctx context.Context) error {
+		// AddACL sets the sys.acl recursively, only the home folder is fixed
+		fi, err := client.GetFileInfoByPath(ctx, "root", home)
+		if err != nil {
+			return err
+		}
+		acls, err := acl.Parse(fi.SysACL, acl.ShortTextForm)
+		if err != nil {
+			return err
+		}
+		if err := acls.SetEntry(acl.TypeUser, ui.UID, "rwx"); err != nil {
+			return err
+		}
+		attr := &eosclient.Attribute{Type: eosclient.SystemAttr, Key: "acl", Val: acls.Serialize()}
+		return client.SetAttr(ctx, "root", attr, false, home)
+	})
+	if acls, err := acl.Parse(fi.SysACL, acl.ShortTextForm); err == nil {
+		for _, e := range acls.Entries {
+			if e.Type == acl.TypeUser && e.Qualifier == ui.UID && strings.Contains(e.Permissions, "rwx") {
+				aclCheck.ok = true
+			}
+		}
+	}
+	if aclCheck.detail == "" {
+		aclCheck.detail = "-"
+	}
+	checks = append(checks, aclCheck)
+	return checks
+}
+
+func prettyHomeChecks(checks []*homeCheck) {
+	cols := []string{"CHECK", "RESULT", "DETAIL"}
+	rows := [][]string{}
+	for _, c := range checks {
+		result := "pass"
+		if !c.ok {
+			result = "fail"
+		}
+		rows = append(rows, []string{c.name, result, c.detail})
+	}
+	pretty(cols, rows)
+}
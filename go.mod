@@ -22,6 +22,7 @@ require (
 	github.com/tj/go-spin v1.1.0
 	go.etcd.io/bbolt v1.3.2
 	gopkg.in/ldap.v3 v3.1.0
+	gopkg.in/yaml.v2 v2.2.4
 )
 
 replace github.com/cs3org/reva => github.com/labkode/reva v0.0.0-20200421155327-0546020c3ee9